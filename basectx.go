@@ -0,0 +1,36 @@
+package clir
+
+import "context"
+
+// SetBaseContext sets a context whose values every Request can read, for
+// dependencies shared by all commands such as a database handle, config
+// or logger. Run layers its own ctx on top: values in ctx take precedence
+// and only ctx controls cancellation and deadlines; base contributes its
+// values alone. A nil base removes it.
+//
+// Example:
+//
+//	base := context.WithValue(context.Background(), dbKey{}, db)
+//	r.SetBaseContext(base)
+//	// in a handler:
+//	db := req.Context().Value(dbKey{}).(*sql.DB)
+func (r *Router) SetBaseContext(base context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetBaseContext")
+	r.baseCtx = base
+}
+
+// layeredContext is a context that falls back to base for values it
+// doesn't hold itself.
+type layeredContext struct {
+	context.Context
+	base context.Context
+}
+
+func (c layeredContext) Value(key any) any {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.base.Value(key)
+}
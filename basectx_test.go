@@ -0,0 +1,46 @@
+package clir
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRouter_SetBaseContext(t *testing.T) {
+	type dbKey struct{}
+	type userKey struct{}
+
+	base, cancelBase := context.WithCancel(context.Background())
+	base = context.WithValue(base, dbKey{}, "db")
+	base = context.WithValue(base, userKey{}, "base-user")
+	cancelBase()
+
+	r := New()
+	r.SetBaseContext(base)
+	var got *Request
+	r.Handle("ping", "Ping", func(req *Request) error {
+		got = req
+		return nil
+	})
+
+	ctx := context.WithValue(context.Background(), userKey{}, "run-user")
+	if err := r.Run(ctx, []string{"ping"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if v := got.Context().Value(dbKey{}); v != "db" {
+		t.Fatalf("expected base value, got %v", v)
+	}
+	if v := got.Context().Value(userKey{}); v != "run-user" {
+		t.Fatalf("expected Run's value to take precedence, got %v", v)
+	}
+	if err := got.Context().Err(); err != nil {
+		t.Fatalf("base cancellation should not apply: %v", err)
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Handle("wait", "Wait", func(req *Request) error { return req.Context().Err() })
+	if err := r.Run(runCtx, []string{"wait"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected Run's cancellation to apply, got %v", err)
+	}
+}
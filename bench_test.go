@@ -0,0 +1,100 @@
+package clir
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// benchRouter registers n command groups. Literal-heavy routers use fixed
+// words at every level; param-heavy routers put a param in the middle of
+// each pattern, so more branches stay alive during matching.
+func benchRouter(n int, paramHeavy bool) *Router {
+	r := New()
+	h := func(*Request) error { return nil }
+	for i := 0; i < n; i++ {
+		group := fmt.Sprintf("group%d", i)
+		if paramHeavy {
+			r.Handle(group+" <name> build", "Build", h)
+			r.Handle(group+" <name> <target> deploy", "Deploy", h)
+			r.Handle(group+" <name>", "Show", h)
+		} else {
+			r.Handle(group+" image build", "Build", h)
+			r.Handle(group+" image list", "List", h)
+			r.Handle(group+" info", "Info", h)
+		}
+	}
+	r.Build()
+	return r
+}
+
+var benchSizes = []struct {
+	name string
+	n    int
+}{
+	{"small", 5},
+	{"medium", 50},
+	{"large", 500},
+}
+
+func BenchmarkBestMatch(b *testing.B) {
+	ctx := context.Background()
+	for _, size := range benchSizes {
+		last := fmt.Sprintf("group%d", size.n-1)
+		cases := []struct {
+			name       string
+			paramHeavy bool
+			argv       []string
+		}{
+			{"literal/hit", false, []string{last, "image", "build", "--push"}},
+			{"literal/miss", false, []string{"nope", "image", "build"}},
+			{"param/hit", true, []string{last, "api", "prod", "deploy"}},
+			{"param/miss", true, []string{"nope", "api", "prod", "deploy"}},
+		}
+		for _, c := range cases {
+			r := benchRouter(size.n, c.paramHeavy)
+			b.Run(size.name+"/"+c.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					r.bestMatch(ctx, c.argv)
+				}
+			})
+			b.Run(size.name+"/"+c.name+"/linear", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					r.bestMatchLinear(ctx, c.argv)
+				}
+			})
+		}
+	}
+}
+
+func TestBestMatch_NoMatchDoesNotAllocate(t *testing.T) {
+	ctx := context.Background()
+	for _, paramHeavy := range []bool{false, true} {
+		r := benchRouter(50, paramHeavy)
+		argv := []string{"nope", "image", "build"}
+
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, _, ok := r.bestMatch(ctx, argv); ok {
+				t.Fatal("unexpected match")
+			}
+		})
+		if allocs != 0 {
+			t.Fatalf("paramHeavy=%v: no-match allocated %v times per run, want 0", paramHeavy, allocs)
+		}
+	}
+}
+
+func TestBestMatch_HitAllocatesOnlyRequest(t *testing.T) {
+	r := benchRouter(50, true)
+	argv := []string{"group7", "api", "prod", "deploy"}
+
+	// One Request plus one Params map (and its bucket).
+	allocs := testing.AllocsPerRun(100, func() {
+		r.bestMatch(context.Background(), argv)
+	})
+	if allocs > 3 {
+		t.Fatalf("match allocated %v times per run, want <= 3", allocs)
+	}
+}
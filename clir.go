@@ -23,7 +23,7 @@
 //	    })
 //	})
 //
-//	if err := r.Run(context.Background(), os.Args[1:]); err != nil {
+//	if err := r.RunArgs(context.Background()); err != nil {
 //	    fmt.Println("Error:", err)
 //	    r.PrintHelp(os.Stdout)
 //	}
@@ -31,11 +31,20 @@ package clir
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Params are the named parameters captured from a pattern,
@@ -48,16 +57,91 @@ type Request struct {
 	// ctx is the underlying context for cancellation, deadlines, values.
 	ctx context.Context
 
+	// route is the matched route, nil for Requests not created by Run.
+	route *route
+
+	// router is the Router that dispatched this Request, or nil.
+	router *Router
+
+	// memo caches typed resolver results for this invocation. It is
+	// shared by shallow copies (WithContext); nil disables memoization.
+	memo *resolverMemo
+
+	// prog is the program name shown in usage lines, including the mount
+	// points for Requests dispatched to a mounted router.
+	prog string
+
+	// argOffset is the index in Args of the first matched token, past any
+	// global flags.
+	argOffset int
+
+	// typed holds the values of params declared with a type, see Get.
+	typed map[string]any
+
+	// depth counts the Dispatch calls that led to this Request.
+	depth int
+
+	// noColor records a --no-color flag on this invocation's command line.
+	noColor bool
+
 	// Args is the full argv slice passed to Router.Run (e.g. os.Args[1:]).
 	Args []string
 
 	// Params are the named parameters captured from the matched pattern.
 	Params Params
 
+	// ParamLists are the token lists captured by list and rest segments.
+	// A list segment stops at the first flag, which starts Extra:
+	// "tag add <tags+>" + argv "tag add a b --push" →
+	// ParamLists{"tags": {"a", "b"}}, Extra{"--push"}.
+	// A rest segment captures everything verbatim, flags included:
+	// "exec <cmd...>" + argv "exec ls -la --color" →
+	// ParamLists{"cmd": {"ls", "-la", "--color"}}.
+	ParamLists map[string][]string
+
 	// Extra are the arguments beyond the pattern, e.g.
 	// "cli comp x run task y arg1 arg2"
 	// when pattern is "comp <component> run task <task>" → Extra{"arg1","arg2"}.
+	// It is the tail of argv after the last matched segment, in its
+	// original order and untouched, even where tokens look like flags or
+	// literals of the pattern. It stops at "--" (see PassThrough); after a
+	// list segment it starts at the first flag, and after a rest segment
+	// it is empty.
 	Extra []string
+
+	// PassThrough are the arguments after the first "--" in argv, taken
+	// verbatim and never matched against the pattern, e.g.
+	// "cli run task build -- -v x" → Extra{}, PassThrough{"-v","x"}.
+	// It is nil when argv has no "--". Routes ending in a rest segment
+	// capture "--" and what follows in their ParamLists entry instead.
+	// Positionals includes these tokens.
+	PassThrough []string
+
+	// Stdout and Stderr are where handlers should write output. They
+	// default to os.Stdout and os.Stderr; see Router.SetOutput.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Globals holds the global flags parsed from before the command when
+	// global-flag parsing is enabled (see Router.SetGlobalFlags); else nil.
+	Globals *Flags
+
+	// StartedAt is when Run was called, before PreRun hooks and matching.
+	// Requests dispatched to a mounted router keep the parent's time.
+	StartedAt time.Time
+
+	// MatchDuration is how long finding the route took, including global
+	// flag parsing.
+	MatchDuration time.Duration
+}
+
+// Elapsed returns the time since the Request started (see StartedAt), or
+// 0 for Requests not created by Run.
+func (r *Request) Elapsed() time.Duration {
+	if r.StartedAt.IsZero() {
+		return 0
+	}
+	return time.Since(r.StartedAt)
 }
 
 // Context returns the underlying context.
@@ -68,6 +152,37 @@ func (r *Request) Context() context.Context {
 	return r.ctx
 }
 
+// Pattern returns the pattern of the matched route, e.g.
+// "comp <component> image build", or "" if the Request wasn't created by
+// Run. Sort hints are not included.
+func (r *Request) Pattern() string {
+	if r.route == nil {
+		return ""
+	}
+	return r.route.String()
+}
+
+// ParamIndex returns the index in Args of the token that the named param
+// was captured from, or -1 if the matched pattern has no such param. For
+// list and rest params it is the index of the first captured token; both
+// params of a key=value segment share their token's index.
+//
+// Example:
+//
+//	// argv: -v comp api image build, pattern "comp <component> image build"
+//	req.ParamIndex("component") // 2
+func (r *Request) ParamIndex(name string) int {
+	if r.route == nil || name == "" {
+		return -1
+	}
+	for i, s := range r.route.segments {
+		if s.param == name || s.key == name || s.val == name {
+			return r.argOffset + i
+		}
+	}
+	return -1
+}
+
 // WithContext returns a shallow copy of Request with ctx replaced.
 func (r *Request) WithContext(ctx context.Context) *Request {
 	cp := *r
@@ -82,25 +197,154 @@ type Handler func(req *Request) error
 type Middleware func(Handler) Handler
 
 type segment struct {
-	lit   string // non-empty for static segment: "comp", "image", "build"
-	param string // non-empty for param segment: e.g. "component" for "<component>"
-	sort  int    // optional sort/level hint derived from numeric prefixes
+	lit     string // non-empty for static segment: "comp", "image", "build"
+	glob    string // non-empty for wildcard literal: "stash@{*}", "v?"
+	param   string // non-empty for param segment: e.g. "component" for "<component>"
+	rest    bool   // param captures all remaining tokens: "<cmd...>"
+	list    bool   // param captures one or more tokens up to a flag: "<tags+>"
+	req     bool   // param rejects the empty token, see Required
+	noFlags bool   // param rejects flag-like tokens, see NoFlags
+	key     string // key param of a key=value segment: "key" for "<key>=<value>"
+	val     string // value param of a key=value segment
+	sort    int    // optional sort/level hint derived from numeric prefixes
+}
+
+// matches reports whether arg can fill segment s.
+func (s segment) matches(arg string) bool {
+	switch {
+	case s.lit != "":
+		return arg == s.lit
+	case s.key != "":
+		return globMatch(s.glob, arg) && arg[0] != '='
+	case s.glob != "":
+		return globMatch(s.glob, arg)
+	case s.list:
+		return arg != "--" && !isFlagLike(arg)
+	default:
+		return s.param != "" && !(s.req && arg == "") && !(s.noFlags && isFlagLike(arg))
+	}
+}
+
+// last reports whether s must be the last segment of a pattern.
+func (s segment) last() bool {
+	return s.rest || s.list
+}
+
+// globMatch reports whether name matches pattern, where '*' matches any
+// run of characters (including none) and '?' matches exactly one.
+// All other characters match themselves.
+func globMatch(pattern, name string) bool {
+	// Iterative matching with single-star backtracking.
+	p, n := 0, 0
+	starP, starN := -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			starP, starN = p, n
+			p++
+		case starP >= 0:
+			starN++
+			p, n = starP+1, starN
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
 }
 
 type route struct {
-	segments []segment
-	handler  Handler
-	desc     string
+	parts      []string // pattern fields as registered, including sort hints
+	segments   []segment
+	handler    Handler
+	desc       string
+	long       string               // detailed help text, see Builder.Long
+	paramSpecs map[string]paramSpec // by param name, see Builder.Param
+	flags      []FlagSpec           // declared with Builder.Flags
+	mount      *Router              // non-nil for routes registered via Mount
+	timeout    time.Duration        // default deadline; 0 means none
+	builtin    bool                 // registered by the router itself (help command)
+	hidden     bool                 // left out of help listings, see CommandHandle.Hidden
+	examples   []string             // see CommandHandle.Example
+	deprecated string               // deprecation message; "" means not deprecated
+	group      string               // help heading, see CommandHandle.Group
+	aliases    []string             // patterns of the routes registered as aliases
+	aliasOf    string               // canonical pattern if registered as an alias
+	ref        *CommandHandle       // handle returned at registration
+	strict     strictMode           // see Builder.Strict
+	extra      extraMode            // as declared, see Builder.Exact and AllowExtra
+	exact      bool                 // matches only without Extra, from extra and DisallowExtra
+	dryRun     bool                 // the router handles --dry-run, see leavesExtra
+
+	// lazy resolves the route's typed context on demand, keyed by its
+	// resolvedKey, so FromContext works before the handler runs.
+	lazy map[any]func(*Request) (any, error)
 }
 
+// BeforeHook runs once per matched invocation, before the handler and
+// outside of any middleware. Returning an error aborts the invocation.
+type BeforeHook func(req *Request) error
+
+// PreRunHook runs before matching with the argv given to Run. It returns
+// the argv to continue with, possibly rewritten, or handled = true to make
+// Run return err without matching anything.
+type PreRunHook func(argv []string) (out []string, handled bool, err error)
+
+// AfterHook runs once per matched invocation, after the handler and
+// outside of any middleware. It receives the handler's error and returns
+// the (possibly transformed) error that Run reports.
+type AfterHook func(req *Request, err error) error
+
 // Router holds all registered routes and can execute them for argv.
+//
+// Registration (Handle, Before, After) is safe for concurrent use. Once
+// the router is frozen, registration panics and Run reads without locking.
 type Router struct {
+	mu     sync.RWMutex
+	frozen atomic.Bool
+	trie   atomic.Pointer[node] // match index; nil until built, reset by Handle
+
 	routes []route
+	preRun []PreRunHook
+	before []BeforeHook
+	after  []AfterHook
+
+	inlineMounts   bool
+	stdout         io.Writer // nil means os.Stdout
+	stderr         io.Writer // nil means os.Stderr
+	dryRun         bool
+	globalFlags    []FlagSpec // nil disables global-flag parsing
+	color          ColorMode
+	session        *Session
+	name           string // program name for usage lines, see SetName
+	stripProg      bool
+	tokenizer      Tokenizer   // nil means SplitLine
+	cache          *matchCache // nil unless EnableMatchCache
+	groupHelp      bool
+	helpWidth      int       // pattern column cap, see SetHelpMaxPatternWidth
+	helpOrder      HelpOrder // see SetHelpOrder
+	baseCtx        context.Context
+	responseFiles  bool
+	noMatchMessage func(argv []string) string
+	helpFlag       bool
+	maxArgs        int // 0 means no limit, see SetMaxArgs
+	disallowExtra  bool
+	defaultArgv    []string // see Default
 }
 
-// New creates an empty Router.
+// New creates an empty Router named after the running program, or
+// unnamed if os.Args is empty.
 func New() *Router {
-	return &Router{}
+	r := &Router{}
+	if len(os.Args) > 0 {
+		r.name = filepath.Base(os.Args[0])
+	}
+	return r
 }
 
 func (rt *route) String() string {
@@ -110,11 +354,28 @@ func (rt *route) String() string {
 			b.WriteByte(' ')
 		}
 		switch {
+		case strings.ContainsAny(s.lit, " \t\n\r"):
+			b.WriteByte('"')
+			b.WriteString(s.lit)
+			b.WriteByte('"')
 		case s.lit != "":
+			if escapedLiteral(s.lit) {
+				b.WriteByte('\\')
+			}
 			b.WriteString(s.lit)
+		case s.key != "":
+			b.WriteString("<" + s.key + ">=<" + s.val + ">")
+		case s.glob != "":
+			b.WriteString(s.glob)
 		case s.param != "":
 			b.WriteByte('<')
 			b.WriteString(s.param)
+			if s.rest {
+				b.WriteString("...")
+			}
+			if s.list {
+				b.WriteByte('+')
+			}
 			b.WriteByte('>')
 		default:
 			b.WriteByte('?')
@@ -123,8 +384,69 @@ func (rt *route) String() string {
 	return b.String()
 }
 
+// escapedLiteral reports whether the literal lit must be written with a
+// backslash in a pattern (see parseSegments), because as is it would be
+// parsed as a sort hint, param, glob or quote, so that String renders
+// patterns that parse back to the same segments.
+func escapedLiteral(lit string) bool {
+	if _, err := strconv.Atoi(lit); err == nil {
+		return true
+	}
+	if strings.HasPrefix(lit, "<") && strings.HasSuffix(lit, ">") {
+		return true
+	}
+	return strings.ContainsAny(lit, "*?") || strings.ContainsAny(lit[:1], `\"'`)
+}
+
+// splitPattern splits a pattern into parts on whitespace. A double- or
+// single-quoted part is a single literal segment, even if it contains
+// spaces or looks like a param or sort hint: `"add user"` matches the one
+// argv token "add user". Quoted parts are returned in escaped form
+// (`\add user`), see parseSegments. An unterminated quote runs to the end.
+func splitPattern(pattern string) []string {
+	var parts []string
+	var cur strings.Builder
+	inPart := false
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case (c == '"' || c == '\'') && !inPart:
+			end := strings.IndexByte(pattern[i+1:], c)
+			if end < 0 {
+				end = len(pattern) - i - 1
+			}
+			parts = append(parts, `\`+pattern[i+1:i+1+end])
+			i += end + 1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inPart {
+				parts = append(parts, cur.String())
+				cur.Reset()
+				inPart = false
+			}
+		default:
+			cur.WriteByte(c)
+			inPart = true
+		}
+	}
+	if inPart {
+		parts = append(parts, cur.String())
+	}
+	return parts
+}
+
 // parseSegments converts pattern parts into segments, interpreting
 // leading integer tokens as sort/level hints for the next segment.
+// Literals containing '*' or '?' are wildcard literals (globs), matching
+// any token of that shape without capturing it. A "<key>=<value>" part
+// matches a single token containing '=' with a non-empty key, capturing
+// both sides; it ranks like a glob.
+// A param ending in "..." is a rest segment, capturing every remaining
+// token; a param ending in "+" is a list segment, capturing one or more
+// tokens up to the first flag. Both are only valid as the last segment.
+// Sort hints never take part in matching. To match a literal integer,
+// escape it with a backslash: `\1` is the literal "1". A backslash escapes
+// any segment, e.g. `\<id>` is the literal "<id>".
 //
 // Example parts:
 //
@@ -148,8 +470,19 @@ func parseSegments(parts []string) []segment {
 		s := segment{sort: pendingSort}
 		pendingSort = 0
 
-		if strings.HasPrefix(p, "<") && strings.HasSuffix(p, ">") {
+		if lit, ok := strings.CutPrefix(p, `\`); ok {
+			s.lit = lit
+		} else if key, val, ok := parseKeyValue(p); ok {
+			s.key, s.val, s.glob = key, val, keyValueGlob
+		} else if strings.HasPrefix(p, "<") && strings.HasSuffix(p, ">") {
 			s.param = p[1 : len(p)-1]
+			if name, ok := strings.CutSuffix(s.param, "..."); ok {
+				s.param, s.rest = name, true
+			} else if name, ok := strings.CutSuffix(s.param, "+"); ok {
+				s.param, s.list = name, true
+			}
+		} else if strings.ContainsAny(p, "*?") {
+			s.glob = p
 		} else {
 			s.lit = p
 		}
@@ -159,46 +492,192 @@ func parseSegments(parts []string) []segment {
 	return segs
 }
 
+// checkParts reports malformed param syntax in unescaped pattern parts:
+// unbalanced angle brackets such as "<component", and empty or bracketed
+// param names such as "<>" or "<a<b>".
+func checkParts(parts []string) error {
+	for _, p := range parts {
+		if strings.HasPrefix(p, `\`) {
+			continue
+		}
+		open, closed := strings.HasPrefix(p, "<"), strings.HasSuffix(p, ">")
+		if open != closed {
+			return fmt.Errorf("unbalanced angle brackets in %q (escape as `\\%s` for a literal)", p, p)
+		}
+		if !open {
+			continue
+		}
+		if key, val, ok := parseKeyValue(p); ok {
+			if strings.ContainsAny(key+val, "<>") {
+				return fmt.Errorf("malformed param %q", p)
+			}
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimSuffix(p[1:len(p)-1], "..."), "+")
+		switch {
+		case name == "":
+			return fmt.Errorf("empty param name in %q", p)
+		case strings.ContainsAny(name, "<>"):
+			return fmt.Errorf("malformed param %q", p)
+		}
+	}
+	return nil
+}
+
+// keyValueGlob is the glob behind key=value segments, so they share glob
+// ranking and trie nodes. The key must also be non-empty (see matches).
+const keyValueGlob = "*=*"
+
+// parseKeyValue splits a "<key>=<value>" pattern part into its param names.
+func parseKeyValue(p string) (key, val string, ok bool) {
+	if !strings.HasPrefix(p, "<") || !strings.HasSuffix(p, ">") {
+		return "", "", false
+	}
+	key, val, ok = strings.Cut(p[1:len(p)-1], ">=<")
+	if !ok || key == "" || val == "" {
+		return "", "", false
+	}
+	return key, val, true
+}
+
 // Handle registers a pattern, description and handler directly.
 //
 // Pattern is a space-separated sequence of segments, where
 //   - literal words match literally: "comp", "image", "build"
 //   - parameters are written as <name>: "<component>", "<task>"
+//   - <key>=<value> matches one token like "env=prod", capturing both sides
+//   - a final <name+> captures one or more tokens up to the first flag
+//     into Request.ParamLists: "tag add <tags+>"
+//   - a final <name...> captures all remaining tokens (at least one)
+//     verbatim into Request.ParamLists, flags included: "exec <cmd...>"
+//   - words containing '*' or '?' are globs: '*' matches any run of
+//     characters and '?' any one, so "stash@{*}" matches "stash@{0}";
+//     escape them to match literally: `list \*`
+//   - bare integers are sort hints for help, not segments; quote or
+//     escape them to match a number literally: `ipv "4"` or `ipv \4`
+//
+// A param name may appear only once per pattern, and brackets must be
+// balanced with a non-empty name; Handle panics otherwise.
+//
+// When several routes match, segments are compared left to right: a
+// literal beats a glob, which beats a param, at the first position where
+// they differ, and a longer match beats its prefix. So a route starting
+// with a literal always beats one starting with a param. Ties go to the
+// earliest registration.
+//
+// The returned handle attaches further help metadata; see CommandHandle.
 //
 // Example:
 //
 //	r.Handle("comp <component> image build", "Build images", handler)
-func (r *Router) Handle(pattern, desc string, h Handler) {
-	parts := strings.Fields(pattern)
+func (r *Router) Handle(pattern, desc string, h Handler) *CommandHandle {
+	parts := splitPattern(pattern)
 	segs := parseSegments(parts)
 
-	r.routes = append(r.routes, route{
+	return r.addRoute("Handle", route{
+		parts:    parts,
 		segments: segs,
 		handler:  h,
 		desc:     desc,
 	})
 }
 
-// 2 bits per segment, left-to-right => early tokens dominate.
-// Max 32 segments if using uint64 (2*32 = 64).
-// matchRank returns a 2-bit-per-segment rank built left->right (early tokens dominate).
+// addRoute registers rt, invalidating the match index, and returns its
+// handle. It panics if rt's pattern is malformed or captures the same
+// param name twice, as such a route would silently never match or
+// overwrite a capture.
+func (r *Router) addRoute(op string, rt route) *CommandHandle {
+	if err := checkParts(rt.parts); err != nil {
+		panic(fmt.Sprintf("clir: invalid pattern %q: %v", strings.Join(rt.parts, " "), err))
+	}
+	if name, ok := rt.duplicateParam(); ok {
+		panic(fmt.Sprintf("clir: duplicate param <%s> in pattern %q", name, rt.String()))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen(op)
+
+	rt.ref = &CommandHandle{router: r}
+	r.applyExtra(&rt)
+	r.routes = append(r.routes, rt)
+	r.trie.Store(nil)
+	return rt.ref
+}
+
+// Build precompiles the registered routes into the match index. It is
+// optional: the index is built lazily on the first Run after any change.
+func (r *Router) Build() {
+	unlock := r.readLock()
+	defer unlock()
+	r.trie.Store(buildTrie(r.routes))
+}
+
+// index returns the match index, building it if needed.
+// Callers hold a read lock (see readLock).
+func (r *Router) index() *node {
+	if t := r.trie.Load(); t != nil {
+		return t
+	}
+	t := buildTrie(r.routes)
+	r.trie.Store(t)
+	return t
+}
+
+// Freeze marks registration as complete. Any later call to Handle,
+// Before, After or a setter such as SetOutput panics, which catches
+// accidental late registration or configuration.
+// After freezing, Run and PrintHelp read the routes without locking.
+func (r *Router) Freeze() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trie.Store(buildTrie(r.routes))
+	r.frozen.Store(true)
+}
+
+// Frozen reports whether Freeze has been called.
+func (r *Router) Frozen() bool {
+	return r.frozen.Load()
+}
+
+// mustNotBeFrozen panics if the router is frozen. Callers hold r.mu.
+func (r *Router) mustNotBeFrozen(op string) {
+	if r.frozen.Load() {
+		panic("clir: " + op + " called on frozen Router")
+	}
+}
+
+// readLock acquires a read lock on the registration state unless the
+// router is frozen, in which case the state is immutable.
+func (r *Router) readLock() (unlock func()) {
+	if r.frozen.Load() {
+		return func() {}
+	}
+	r.mu.RLock()
+	return r.mu.RUnlock
+}
+
+// matchArgv returns a 2-bit-per-segment rank built left->right (early tokens dominate).
 // Encoding:
 //
-//	10 = literal match
+//	11 = literal match
+//	10 = glob match
 //	01 = param match
 //
 // With this encoding, longer matches always rank higher than shorter matches (since codes are non-zero).
-// Uses uint64 => max 32 segments.
-func (rt *route) matchArgv(argv []string) (rank uint64, params Params) {
+// Uses uint64 => max 32 segments. A rank of 0 means no match.
+//
+// matchArgv doesn't allocate; use params to materialize the captured
+// parameters once a route has won.
+func (rt *route) matchArgv(argv []string) (rank uint64) {
 	segs := rt.segments
 	if len(argv) < len(segs) {
-		return 0, nil
+		return 0
 	}
 	if len(segs) > 32 {
-		return 0, nil
+		return 0
 	}
 
-	params = Params{}
 	for i, s := range segs {
 		arg := argv[i]
 
@@ -206,14 +685,24 @@ func (rt *route) matchArgv(argv []string) (rank uint64, params Params) {
 		switch {
 		case s.lit != "":
 			if arg != s.lit {
-				return 0, nil
+				return 0
+			}
+			code = 0b11
+		case s.glob != "":
+			if !s.matches(arg) {
+				return 0
 			}
 			code = 0b10
 		case s.param != "":
-			params[s.param] = arg
+			if s.last() && i != len(segs)-1 {
+				return 0
+			}
+			if !s.matches(arg) {
+				return 0
+			}
 			code = 0b01
 		default:
-			return 0, nil
+			return 0
 		}
 
 		// rank = (rank << 2) | code // Right-left LSB-first placement (longest wins)
@@ -221,26 +710,179 @@ func (rt *route) matchArgv(argv []string) (rank uint64, params Params) {
 		rank |= code << shift
 
 	}
+	if rt.leavesExtra(argv) {
+		return 0
+	}
+
+	return rank
+}
+
+// accepts reports whether argv satisfies rt's required and NoFlags
+// params, list params, key=value keys and, for exact routes, leaves no
+// Extra but router flags. The trie matches params by position and
+// key=value segments by their glob only, and checks this once a route is
+// found.
+func (rt *route) accepts(argv []string) bool {
+	for i, s := range rt.segments {
+		if (s.req || s.noFlags || s.list || s.key != "") && !s.matches(argv[i]) {
+			return false
+		}
+	}
+	return !rt.leavesExtra(argv)
+}
+
+// consumesAll reports whether matching argv against rt would leave Extra
+// empty. argv must match rt's segments.
+func (rt *route) consumesAll(argv []string) bool {
+	return len(rt.leftover(argv)) == 0
+}
+
+// leftover returns the tokens of argv that rt leaves in Extra. argv must
+// match rt's segments.
+func (rt *route) leftover(argv []string) []string {
+	n := len(rt.segments)
+	if n == 0 {
+		return argv
+	}
+	switch s := rt.segments[n-1]; {
+	case s.rest:
+		return nil
+	case s.list:
+		for i := n - 1; i < len(argv); i++ {
+			if !s.matches(argv[i]) {
+				return argv[i:]
+			}
+		}
+		return nil
+	}
+	return argv[n:]
+}
 
-	return rank, params
+// params builds the Params captured by rt from a matching argv.
+func (rt *route) params(argv []string) Params {
+	params := Params{}
+	for i, s := range rt.segments {
+		if s.param != "" && !s.last() {
+			params[s.param] = argv[i]
+		}
+		if s.key != "" {
+			params[s.key], params[s.val], _ = strings.Cut(argv[i], "=")
+		}
+	}
+	return params
 }
 
-// bestMatch finds the best matching route by highest rank.
+// duplicateParam returns a param name captured more than once by rt.
+func (rt *route) duplicateParam() (string, bool) {
+	seen := map[string]bool{}
+	for _, s := range rt.segments {
+		for _, name := range []string{s.param, s.key, s.val} {
+			if name == "" {
+				continue
+			}
+			if seen[name] {
+				return name, true
+			}
+			seen[name] = true
+		}
+	}
+	return "", false
+}
+
+// hasParam reports whether any segment of rt captures the named param.
+func (rt *route) hasParam(name string) bool {
+	for _, s := range rt.segments {
+		if s.param == name || s.key == name || s.val == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rest returns the name of rt's rest segment, if it has one.
+func (rt *route) rest() (string, bool) {
+	if n := len(rt.segments); n > 0 && rt.segments[n-1].rest {
+		return rt.segments[n-1].param, true
+	}
+	return "", false
+}
+
+// capture fills req's Params, ParamLists and Extra from a matching argv.
+func (rt *route) capture(req *Request, argv []string) {
+	req.Params = rt.params(argv)
+	n := len(rt.segments)
+	if name, ok := rt.rest(); ok {
+		req.ParamLists = map[string][]string{name: argv[n-1:]}
+		req.Extra = nil
+		return
+	}
+	if s := rt.segments[n-1]; s.list {
+		end := n
+		for end < len(argv) && s.matches(argv[end]) {
+			end++
+		}
+		req.ParamLists = map[string][]string{s.param: argv[n-1 : end]}
+		n = end
+	}
+	req.Extra = argv[n:]
+}
+
+// bestMatch finds the best matching route by highest rank using the
+// precompiled trie. Params are only built for the winning route.
+// Ties (routes of the same shape, e.g. "users <id>" and "users <name>")
+// always resolve to the earliest-registered route.
 // Returns (routePtr, reqPtr, ok).
 func (r *Router) bestMatch(ctx context.Context, argv []string) (*route, *Request, bool) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
+	rt, ok := r.lookup(argv)
+	if !ok {
+		return nil, nil, false
+	}
+
+	req := &Request{
+		ctx:    ctx,
+		route:  rt,
+		Args:   argv,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	rt.capture(req, argv)
+	return rt, req, true
+}
+
+// lookup returns the best matching route without building a Request.
+// It doesn't allocate once the trie is built, unless the match cache is
+// enabled.
+func (r *Router) lookup(argv []string) (*route, bool) {
+	t := r.index()
+	var idx int
+	if r.cache != nil {
+		idx = r.cache.get(t, argv, func() int { return t.match(r.routes, argv, 0) })
+	} else {
+		idx = t.match(r.routes, argv, 0)
+	}
+	if idx == -1 {
+		return nil, false
+	}
+	return &r.routes[idx], true
+}
+
+// bestMatchLinear is the reference implementation of bestMatch: it ranks
+// every route with matchArgv and keeps the first highest rank. The trie
+// must agree with it; tests compare the two.
+func (r *Router) bestMatchLinear(ctx context.Context, argv []string) (*route, *Request, bool) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	bestIdx := -1
 	var bestRank uint64
-	var bestParams Params
-	var bestExtra []string
 
 	for i := range r.routes {
-		rt := &r.routes[i]
-
-		rank, params := rt.matchArgv(argv)
+		rank := r.routes[i].matchArgv(argv)
 		if rank == 0 {
 			continue
 		}
@@ -248,8 +890,6 @@ func (r *Router) bestMatch(ctx context.Context, argv []string) (*route, *Request
 		if bestIdx == -1 || rank > bestRank {
 			bestIdx = i
 			bestRank = rank
-			bestParams = params
-			bestExtra = argv[len(rt.segments):]
 		}
 	}
 
@@ -257,64 +897,543 @@ func (r *Router) bestMatch(ctx context.Context, argv []string) (*route, *Request
 		return nil, nil, false
 	}
 
+	rt := &r.routes[bestIdx]
 	req := &Request{
 		ctx:    ctx,
+		route:  rt,
 		Args:   argv,
-		Params: bestParams,
-		Extra:  bestExtra,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	rt.capture(req, argv)
+	return rt, req, true
+}
+
+// Before registers a hook that runs after a successful match and before
+// the handler, regardless of builder scope. Hooks run in registration
+// order; the first error aborts the invocation and is returned from Run.
+func (r *Router) Before(fn BeforeHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("Before")
+	r.before = append(r.before, fn)
+}
+
+// Default sets the command run when argv names none: when argv is empty
+// or starts with a flag and nothing matches it as is, Run retries with
+// the words of command prepended, so bare "mytool" or "mytool --short"
+// runs "status" for Default("status"). A route that matches argv always
+// wins, and a mistyped command still fails instead of running the
+// default. Request.Args includes the prepended words. An empty command
+// removes the default.
+//
+// Example:
+//
+//	r.Handle("status", "Show status", showStatus)
+//	r.Default("status")
+func (r *Router) Default(command string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("Default")
+	r.defaultArgv = nil
+	if words := strings.Fields(command); len(words) > 0 {
+		r.defaultArgv = words
 	}
-	return &r.routes[bestIdx], req, true
+}
+
+// SetMaxArgs makes Run reject argv longer than n with an error before
+// matching, to bound the work done for pathological input, e.g. from
+// Serve or REPL. The limit applies after response files are expanded and
+// PreRun hooks have run. Zero, the default, means no limit.
+//
+// Example:
+//
+//	r.SetMaxArgs(256)
+//	// 300 arguments fail with "too many arguments: 300 (limit 256)"
+func (r *Router) SetMaxArgs(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetMaxArgs")
+	r.maxArgs = n
+}
+
+// PreRun registers a hook that runs on every Run before matching, in
+// registration order, each receiving the previous hook's argv. It can
+// rewrite argv (e.g. expand an alias) or handle the invocation itself
+// (e.g. intercept --version anywhere in argv). A non-nil error aborts
+// Run with that error.
+//
+// Example:
+//
+//	r.PreRun(func(argv []string) ([]string, bool, error) {
+//	    if slices.Contains(argv, "--version") {
+//	        fmt.Println(version)
+//	        return nil, true, nil
+//	    }
+//	    return argv, false, nil
+//	})
+func (r *Router) PreRun(fn PreRunHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("PreRun")
+	r.preRun = append(r.preRun, fn)
+}
+
+// After registers a hook that runs after the handler, regardless of
+// builder scope. Each hook receives the error so far (possibly nil) and
+// returns the error passed on to the next hook, so it can transform or
+// swallow it. After hooks also run when a Before hook aborts.
+func (r *Router) After(fn AfterHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("After")
+	r.after = append(r.after, fn)
 }
 
 // Run attempts to match argv against registered routes and executes
 // the first matching handler. ctx becomes the root context for the Request.
+// If nothing matches, it returns a *NoMatchError.
 func (r *Router) Run(ctx context.Context, argv []string) error {
-	rt, req, ok := r.bestMatch(ctx, argv)
+	return r.run(ctx, argv, nil)
+}
+
+// RunArgs runs the program's own arguments, os.Args[1:], so main can be
+// a one-liner. Use Run for anything else, e.g. tests.
+//
+// Example:
+//
+//	if err := r.RunArgs(context.Background()); err != nil {
+//	    fmt.Fprintln(os.Stderr, "Error:", err)
+//	    os.Exit(1)
+//	}
+func (r *Router) RunArgs(ctx context.Context) error {
+	var argv []string
+	if len(os.Args) > 1 {
+		argv = os.Args[1:]
+	}
+	return r.Run(ctx, argv)
+}
+
+// run is Run with an optional parent Request, used by mounted routers to
+// inherit the parent's output writers when they have none of their own.
+func (r *Router) run(ctx context.Context, argv []string, parent *Request) error {
+	start := time.Now()
+	if parent != nil && !parent.StartedAt.IsZero() {
+		start = parent.StartedAt
+	}
+	unlock := r.readLock()
+	preRun := r.preRun
+	responseFiles := r.responseFiles
+	maxArgs := r.maxArgs
+	unlock()
+	if responseFiles {
+		var err error
+		if argv, err = expandResponseFiles(argv, 0); err != nil {
+			return err
+		}
+	}
+	for _, fn := range preRun {
+		out, handled, err := fn(argv)
+		if handled || err != nil {
+			return err
+		}
+		argv = out
+	}
+	if maxArgs > 0 && len(argv) > maxArgs {
+		return fmt.Errorf("too many arguments: %d (limit %d)", len(argv), maxArgs)
+	}
+
+	unlock = r.readLock()
+	matchStart := time.Now()
+
+	if parent == nil && r.stripProg && len(argv) > 0 && r.isProgramName(argv[0]) {
+		argv = argv[1:]
+	}
+	args := argv
+	var globals *Flags
+	var leadingHelp bool // --help or -h among the global flags
+	if r.globalFlags != nil {
+		specs := append(r.globalFlags[:len(r.globalFlags):len(r.globalFlags)],
+			FlagSpec{Name: noColorFlag, Bool: true})
+		help, addHelp := r.helpFlagSpec(specs)
+		if addHelp {
+			specs = append(specs, help)
+		}
+		var err error
+		if globals, argv, err = parseFlags(argv, specs, true); err != nil {
+			unlock()
+			return err
+		}
+		leadingHelp = addHelp && globals.Count(help.key()) > 0
+	} else {
+		argv = trimNoColor(argv)
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.baseCtx != nil {
+		ctx = layeredContext{ctx, r.baseCtx}
+	}
+	ctx = withSession(ctx, r.session)
+
+	// Tokens after "--" are passed through and never matched.
+	match, pass := argv, []string(nil)
+	if i := slices.Index(argv, "--"); i >= 0 {
+		match, pass = argv[:i], argv[i+1:]
+	}
+
+	// --no-color applies to this invocation only; a mounted router's
+	// invocation inherits it from the parent's command line.
+	noColor := parent != nil && parent.noColor
+
+	rt, req, ok := r.bestMatch(ctx, match)
+	if tokens, i, found := r.helpFlagTokens(match); leadingHelp || found && !capturesAt(rt, ok, i) {
+		if !found {
+			tokens = match
+		}
+		w := r.stdout
+		if w == nil && parent != nil {
+			w = parent.Stdout
+		}
+		if w == nil {
+			w = os.Stdout
+		}
+		prog := r.name
+		if parent != nil && parent.route != nil && parent.route.mount == r {
+			prog = joinProg(parent.prog, parent.route.String())
+		}
+		unlock()
+		r.printFlagHelp(w, prog, tokens, noColor || hasNoColor(args))
+		return nil
+	}
+	if !ok && len(r.defaultArgv) > 0 && (len(match) == 0 || isFlagLike(match[0])) {
+		prefix := len(args) - len(argv)
+		argv = slices.Concat(r.defaultArgv, argv)
+		args = slices.Concat(args[:prefix], argv)
+		match = argv[:len(r.defaultArgv)+len(match)]
+		rt, req, ok = r.bestMatch(ctx, match)
+	}
+	if !ok && pass != nil {
+		// "exec -- ls -la" still reaches "exec <cmd...>", whose rest
+		// segment takes "--" verbatim.
+		if rt, req, ok = r.bestMatch(ctx, argv); ok {
+			if _, ok = rt.rest(); !ok {
+				rt, req = nil, nil
+			}
+		}
+	}
+	matchDur := time.Since(matchStart)
+	before, after := r.before, r.after
+	stdout, stderr := r.stdout, r.stderr
+	dryRun := r.dryRun
+	prog := r.name
+	color := r.color
+	style := listStyle{width: r.helpWidth, order: r.helpOrder}
+	var noMatch *NoMatchError
+	var group []helpEntry
+	if !ok && r.groupHelp && len(match) > 0 {
+		group = r.prefixEntries(match)
+	}
+	if !ok && group == nil {
+		noMatch = r.noMatch(match)
+		if parent == nil {
+			noMatch.hint = r.programNameHint(args)
+		}
+	}
+	unlock()
+
 	if !ok {
-		return fmt.Errorf("no matching command for `%s`", strings.Join(argv, " "))
+		noColor = noColor || hasNoColor(args)
+		if group != nil {
+			w := stdout
+			if w == nil && parent != nil {
+				w = parent.Stdout
+			}
+			if w == nil {
+				w = os.Stdout
+			}
+			style.color = colorFor(color, noColor, w)
+			printMatching(w, match, group, style)
+			return nil
+		}
+		noMatch.noColor = noColor
+		return noMatch
+	}
+	if name, ok := rt.rest(); ok {
+		// Tokens captured by a rest segment are passed through untouched,
+		// "--" included.
+		if pass != nil {
+			rt.capture(req, argv)
+		}
+		noColor = noColor || hasNoColor(args[:len(args)-len(req.ParamLists[name])])
+	} else {
+		noColor = noColor || hasNoColor(args)
+		req.PassThrough = pass
+	}
+	req.noColor = noColor
+	req.Args = args
+	req.argOffset = len(args) - len(argv)
+	req.Globals = globals
+	req.StartedAt = start
+	req.MatchDuration = matchDur
+	req.router = r
+	req.memo = &resolverMemo{}
+	req.prog = prog
+
+	if parent != nil {
+		req.depth = parent.depth
+		if parent.route != nil && parent.route.mount == r {
+			req.prog = joinProg(parent.prog, parent.route.String())
+		}
+		if stdout == nil {
+			stdout = parent.Stdout
+		}
+		if stderr == nil {
+			stderr = parent.Stderr
+		}
+	}
+	if stdout != nil {
+		req.Stdout = stdout
+	}
+	if stderr != nil {
+		req.Stderr = stderr
+	}
+	return r.execute(rt, req, before, after, dryRun)
+}
+
+// execute runs a matched route for a fully built Request: it applies the
+// strict, dry-run and timeout settings, runs the handler within the
+// router-level hooks and shows the command's help on ErrShowHelp.
+func (r *Router) execute(rt *route, req *Request, before []BeforeHook, after []AfterHook, dryRun bool) error {
+	if err := checkStrict(rt, req, dryRun); err != nil {
+		return err
+	}
+	if err := parseTyped(rt, req); err != nil {
+		return err
+	}
+	if dryRun && req.HasFlag(dryRunFlag) {
+		printPlan(req.Stdout, rt, req, req.colorEnabled(req.Stdout))
+		return nil
+	}
+
+	if rt.timeout > 0 {
+		d := rt.timeout
+		if v, ok := req.Flag("timeout"); ok {
+			var err error
+			if d, err = time.ParseDuration(v); err != nil {
+				return fmt.Errorf("invalid --timeout value %q: %w", v, err)
+			}
+			if d <= 0 {
+				return fmt.Errorf("invalid --timeout value %q: must be positive", v)
+			}
+		}
+		tctx, cancel := context.WithTimeout(req.Context(), d)
+		defer cancel()
+		req.ctx = tctx
+	}
+	if rt.deprecated != "" {
+		warnDeprecated(req.Stderr, req.prog, rt)
+	}
+	err := serve(rt, req, before, after)
+	var shown *helpShownError
+	if errors.Is(err, ErrShowHelp) && !errors.As(err, &shown) {
+		if err != ErrShowHelp {
+			fmt.Fprintln(req.Stderr, "Error:", err)
+		}
+		printCommandHelp(req.Stderr, req.prog, rt, req.colorEnabled(req.Stderr))
+		return &helpShownError{err}
+	}
+	return err
+}
+
+// SetGlobalFlags enables global-flag parsing: flags given before the
+// command are parsed against specs (see Request.ParseFlags), stripped
+// before matching and exposed as Request.Globals. An unknown leading flag
+// fails with "unknown flag --name" instead of a generic no-match error.
+// Calling it with no specs still enables parsing, rejecting every
+// leading flag.
+//
+// Example:
+//
+//	r.SetGlobalFlags(clir.FlagSpec{Name: "verbose", Short: "v", Bool: true})
+//	// "mytool -v comp api image build" matches "comp <component> image build"
+//	// "mytool --verbos comp api" fails with "unknown flag --verbos"
+func (r *Router) SetGlobalFlags(specs ...FlagSpec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetGlobalFlags")
+	r.globalFlags = append([]FlagSpec{}, specs...)
+}
+
+// SetOutput sets the writers exposed to handlers as Request.Stdout and
+// Request.Stderr. A nil writer restores the default (os.Stdout/os.Stderr).
+//
+// Example (capturing output in a test):
+//
+//	var out bytes.Buffer
+//	r.SetOutput(&out, &out)
+//	_ = r.Run(ctx, []string{"hello"})
+func (r *Router) SetOutput(stdout, stderr io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetOutput")
+	r.stdout = stdout
+	r.stderr = stderr
+}
+
+// serve executes the matched route wrapped in the router-level hooks.
+func serve(rt *route, req *Request, before []BeforeHook, after []AfterHook) error {
+	var err error
+	for _, fn := range before {
+		if err = fn(req); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = rt.handler(req)
+	}
+	for _, fn := range after {
+		err = fn(req, err)
+	}
+	return err
+}
+
+// helpEntry is a single line of help output.
+type helpEntry struct {
+	segs    []segment
+	pat     string
+	sortPat string
+	desc    string
+	group   string
+}
+
+// helpEntries collects one entry per route. Mount points are listed as
+// "<prefix> ..." or, with inline mount help, replaced by the mounted
+// router's own entries under the prefix. Callers hold a read lock.
+func (r *Router) helpEntries() []helpEntry {
+	entries := make([]helpEntry, 0, len(r.routes))
+
+	for _, rt := range r.routes {
+		if rt.hidden {
+			continue
+		}
+		var sortParts []string
+		for _, s := range rt.segments {
+			if lit := s.lit + s.glob; lit != "" && s.key == "" {
+				sortParts = append(sortParts, fmt.Sprintf("%d %s", s.sort, lit))
+			}
+		}
+		e := helpEntry{
+			segs:    rt.segments,
+			pat:     rt.String(),
+			sortPat: strings.Join(sortParts, " "),
+			desc:    rt.desc,
+			group:   rt.group,
+		}
+
+		if rt.mount != nil {
+			if r.inlineMounts {
+				entries = append(entries, rt.mount.prefixedHelpEntries(e)...)
+				continue
+			}
+			e.pat += " ..."
+		}
+		entries = append(entries, e)
 	}
-	return rt.handler(req)
+
+	return entries
 }
 
 // PrintHelp prints all registered patterns and their descriptions,
-// sorted alphabetically by pattern.
+// sorted by pattern (see SetHelpOrder), under an "Available commands:"
+// header. Commands with a group (see CommandHandle.Group) are listed
+// under the group's heading instead.
 func (r *Router) PrintHelp(w io.Writer) {
+	r.printHelp(w, false)
+}
+
+// printHelp is PrintHelp, without color if noColor is set.
+func (r *Router) printHelp(w io.Writer, noColor bool) {
+	unlock := r.readLock()
+	defer unlock()
+
 	if len(r.routes) == 0 {
 		fmt.Fprintln(w, "No commands registered.")
 		return
 	}
 
-	entries := make([]struct {
-		pat     string
-		sortPat string
-		desc    string
-	}, len(r.routes))
+	style := r.listStyle(w, noColor)
+	var ungrouped []helpEntry
+	var groups []string
+	grouped := map[string][]helpEntry{}
+	for _, e := range r.helpEntries() {
+		if e.group == "" {
+			ungrouped = append(ungrouped, e)
+			continue
+		}
+		if _, ok := grouped[e.group]; !ok {
+			groups = append(groups, e.group)
+		}
+		grouped[e.group] = append(grouped[e.group], e)
+	}
 
-	for i, rt := range r.routes {
-		var sortParts []string
-		for _, s := range rt.segments {
-			if s.lit != "" {
-				sortParts = append(sortParts, fmt.Sprintf("%d %s", s.sort, s.lit))
-			}
+	if len(ungrouped) > 0 || len(groups) == 0 {
+		fmt.Fprintln(w, "Available commands:")
+		printEntries(w, ungrouped, style)
+	}
+	for i, g := range groups {
+		if i > 0 || len(ungrouped) > 0 {
+			fmt.Fprintln(w)
 		}
-		entries[i].pat = rt.String()
-		entries[i].sortPat = strings.Join(sortParts, " ")
-		entries[i].desc = rt.desc
+		fmt.Fprintf(w, "%s\n", bold(g+":", style.color))
+		printEntries(w, grouped[g], style)
 	}
+}
 
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].sortPat < entries[j].sortPat
-	})
+// listStyle controls how printEntries lays out a command list.
+type listStyle struct {
+	color bool
+	width int       // pattern column cap; 0 means none
+	order HelpOrder // how entries are sorted
+}
+
+// listStyle returns the list style for output to w, without color if
+// noColor is set. Callers hold a read lock.
+func (r *Router) listStyle(w io.Writer, noColor bool) listStyle {
+	return listStyle{color: colorFor(r.color, noColor, w), width: r.helpWidth, order: r.helpOrder}
+}
+
+// printEntries prints help entries as an aligned two-column list, ordered
+// as set with SetHelpOrder. With color, patterns are printed in bold.
+// A positive width caps the pattern column: longer patterns get a line of
+// their own, with the description below in the column.
+func printEntries(w io.Writer, entries []helpEntry, style listStyle) {
+	switch style.order {
+	case HelpOrderSortHint:
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].sortPat < entries[j].sortPat
+		})
+	case HelpOrderAlphabetical:
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].pat < entries[j].pat
+		})
+	}
 
+	color, maxWidth := style.color, style.width
 	maxLen := 0
 	for _, e := range entries {
-		if l := len(e.pat); l > maxLen {
+		if l := len(e.pat); l > maxLen && (maxWidth <= 0 || l <= maxWidth) {
 			maxLen = l
 		}
 	}
-	format := fmt.Sprintf("  %%-%ds  %%s\n", maxLen)
 	for _, e := range entries {
-		fmt.Fprintf(w, format, e.pat, e.desc)
+		if len(e.pat) > maxLen {
+			fmt.Fprintf(w, "  %s\n  %s  %s\n", bold(e.pat, color), strings.Repeat(" ", maxLen), e.desc)
+			continue
+		}
+		pad := strings.Repeat(" ", maxLen-len(e.pat))
+		fmt.Fprintf(w, "  %s%s  %s\n", bold(e.pat, color), pad, e.desc)
 	}
 }
 
@@ -332,14 +1451,39 @@ func (r *Router) Routes(fn func(b *Builder)) {
 // Builder provides a chi-style API to build routes with prefixes
 // and middleware (untyped).
 type Builder struct {
-	router *Router
-	prefix []string
-	mws    []Middleware
+	router     *Router
+	prefix     []string
+	mws        []Middleware
+	timeout    time.Duration
+	long       string // applies to routes handled directly, not to Route children
+	paramSpecs map[string]paramSpec
+	flags      []FlagSpec
+	strict     strictMode
+	extra      extraMode
+}
+
+// derive returns a copy of b that can be changed without affecting b.
+func (b *Builder) derive() *Builder {
+	return &Builder{
+		router:     b.router,
+		prefix:     append([]string{}, b.prefix...),
+		mws:        append([]Middleware{}, b.mws...),
+		timeout:    b.timeout,
+		long:       b.long,
+		paramSpecs: maps.Clone(b.paramSpecs),
+		flags:      slices.Clone(b.flags),
+		strict:     b.strict,
+		extra:      b.extra,
+	}
 }
 
 // Route adds a path prefix (space-separated segments) for all routes
 // defined in the callback.
 //
+// Params is a flat map, so a param name can be captured only once per
+// route: a route nested under "image <name>" can't use <name> again, and
+// Handle panics naming the enclosing prefix if it does.
+//
 // Example:
 //
 //	b.Route("comp <component>", func(b *Builder) {
@@ -348,12 +1492,9 @@ type Builder struct {
 //	    })
 //	})
 func (b *Builder) Route(path string, fn func(b *Builder)) {
-	parts := strings.Fields(path)
-	child := &Builder{
-		router: b.router,
-		prefix: append(append([]string{}, b.prefix...), parts...),
-		mws:    append([]Middleware{}, b.mws...), // copy for isolation
-	}
+	child := b.derive()
+	child.prefix = append(child.prefix, splitPattern(path)...)
+	child.long = ""
 	fn(child)
 }
 
@@ -365,23 +1506,84 @@ func (b *Builder) Route(path string, fn func(b *Builder)) {
 //	    b.Handle("list", "List components", handler)
 //	})
 func (b *Builder) With(mws ...Middleware) *Builder {
-	return &Builder{
-		router: b.router,
-		prefix: append([]string{}, b.prefix...),
-		mws:    append(append([]Middleware{}, b.mws...), mws...),
-	}
+	child := b.derive()
+	child.mws = append(child.mws, mws...)
+	return child
+}
+
+// Timeout sets a default deadline for all routes defined in the returned
+// builder. Run derives the handler's context with this timeout; users can
+// override it per invocation with --timeout <duration> (e.g. --timeout 2m).
+// A --timeout that is zero or negative fails the invocation.
+//
+// Example:
+//
+//	b.Timeout(30*time.Second).Handle("sync", "Sync remote state", handler)
+func (b *Builder) Timeout(d time.Duration) *Builder {
+	child := b.derive()
+	child.timeout = d
+	return child
+}
+
+// Long sets the detailed help text for routes handled by the returned
+// builder. It is shown by per-command help (the help command and
+// ErrShowHelp) instead of the one-line description, which remains the
+// summary in PrintHelp. The text is printed verbatim, so it may span
+// several paragraphs.
+//
+// Example:
+//
+//	b.Long(`Build images for a component.
+//
+//	Images are tagged with the current commit unless --tag is given.`).
+//	    Handle("image build", "Build images", handler)
+func (b *Builder) Long(text string) *Builder {
+	child := b.derive()
+	child.long = text
+	return child
 }
 
-// Handle registers a handler under the current prefix + relative path.
+// Handle registers a handler under the current prefix + relative path
+// and returns its handle (see CommandHandle).
 //
 // Example (under a prefix "comp <component>"):
 //
 //	b.Handle("image build", "Build images", handler)
 //	// pattern: "comp <component> image build"
-func (b *Builder) Handle(path, desc string, h Handler) {
-	parts := strings.Fields(path)
+func (b *Builder) Handle(path, desc string, h Handler) *CommandHandle {
+	return b.handle(path, desc, h)
+}
+
+// HandlerSpec is a path/description/handler tuple for Builder.Handles.
+type HandlerSpec struct {
+	Path    string
+	Desc    string
+	Handler Handler
+}
+
+// Handles registers several handlers under the current prefix, in order,
+// as if Handle were called for each spec.
+//
+// Example:
+//
+//	b.Route("image", func(b *clir.Builder) {
+//	    b.Handles(
+//	        clir.HandlerSpec{Path: "build", Desc: "Build images", Handler: buildImages},
+//	        clir.HandlerSpec{Path: "push", Desc: "Push images", Handler: pushImages},
+//	        clir.HandlerSpec{Path: "list", Desc: "List images", Handler: listImages},
+//	    )
+//	})
+func (b *Builder) Handles(specs ...HandlerSpec) {
+	for _, s := range specs {
+		b.handle(s.Path, s.Desc, s.Handler)
+	}
+}
+
+// handle registers h under the current prefix + path, wrapped in the
+// builder's middleware and carrying its timeout.
+func (b *Builder) handle(path, desc string, h Handler) *CommandHandle {
+	parts := splitPattern(path)
 	full := append(append([]string{}, b.prefix...), parts...)
-	pattern := strings.Join(full, " ")
 
 	// Apply middleware chain (outermost first).
 	wrapped := h
@@ -389,7 +1591,34 @@ func (b *Builder) Handle(path, desc string, h Handler) {
 		wrapped = b.mws[i](wrapped)
 	}
 
-	b.router.Handle(pattern, desc, wrapped)
+	segs := parseSegments(full)
+	for i, s := range segs {
+		if s.param != "" && !s.last() {
+			segs[i].req = b.paramSpecs[s.param].required
+			segs[i].noFlags = b.paramSpecs[s.param].noFlags
+		}
+	}
+	if len(b.prefix) > 0 {
+		rt := &route{segments: segs}
+		pre := &route{segments: parseSegments(b.prefix)}
+		if name, ok := rt.duplicateParam(); ok && pre.hasParam(name) {
+			panic(fmt.Sprintf("clir: duplicate param <%s> in pattern %q: <%s> is already captured by the enclosing prefix %q",
+				name, rt.String(), name, pre.String()))
+		}
+	}
+
+	return b.router.addRoute("Handle", route{
+		parts:      full,
+		segments:   segs,
+		handler:    wrapped,
+		desc:       desc,
+		long:       b.long,
+		paramSpecs: b.paramSpecs,
+		flags:      b.flags,
+		timeout:    b.timeout,
+		strict:     b.strict,
+		extra:      b.extra,
+	})
 }
 
 // ---- Typed context support ----
@@ -397,6 +1626,12 @@ func (b *Builder) Handle(path, desc string, h Handler) {
 // Resolver resolves a typed context object T from the Request.
 type Resolver[T any] func(*Request) (T, error)
 
+// RequestResolver is a Resolver that may also augment the Request, e.g.
+// with an auth token or tracing span in its context. It returns the
+// Request handlers should see, normally derived from the given one with
+// Request.WithContext; nil keeps the Request unchanged.
+type RequestResolver[T any] func(*Request) (T, *Request, error)
+
 // ContextHandler is a handler that operates on a typed context object
 // plus the Request.
 type ContextHandler[T any] func(req *Request, ctx T) error
@@ -404,60 +1639,178 @@ type ContextHandler[T any] func(req *Request, ctx T) error
 // ContextBuilder is a typed variant of Builder.
 // It shares the same prefix/router/middleware machinery but
 // resolves a typed context T for each handler.
+//
+// The handler resolves T once the middleware added with With has run,
+// whether it was added before or after the typed lift, so context values
+// that middleware sets with Request.WithContext are visible to resolvers
+// at every layer. Middleware added with WithResolved runs after
+// resolution. Before hooks run outside the middleware and can't pass
+// values this way. A Before hook or middleware calling FromContext
+// resolves T at that point, before any inner middleware, and the handler
+// gets the memoized result.
 type ContextBuilder[T any] struct {
 	base    *Builder
 	resolve Resolver[T]
+	inject  []injector // from WithContextValue and WithRequestContext layers, outermost first
+
+	// resolved is middleware run after resolution, see WithResolved.
+	resolved []Middleware
 }
 
+// injector resolves a layer's value and stores it in the request context.
+type injector func(*Request) (*Request, error)
+
 // Route adds a path prefix (space-separated segments) for all routes
 // defined in the callback, keeping the same typed context T.
 func (b *ContextBuilder[T]) Route(path string, fn func(b *ContextBuilder[T])) {
-	childBase := &Builder{
-		router: b.base.router,
-		prefix: append(append([]string{}, b.base.prefix...), strings.Fields(path)...),
-		mws:    append([]Middleware{}, b.base.mws...), // copy
-	}
+	childBase := b.base.derive()
+	childBase.prefix = append(childBase.prefix, splitPattern(path)...)
+	childBase.long = ""
 	fn(&ContextBuilder[T]{
-		base:    childBase,
-		resolve: b.resolve,
+		base:     childBase,
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: b.resolved,
 	})
 }
 
 // With adds middleware to all routes defined in the returned typed builder.
+// It runs before the handler resolves the typed context, but can resolve
+// it early with FromContext; see WithResolved for middleware that should
+// only run once resolution succeeded.
 func (b *ContextBuilder[T]) With(mws ...Middleware) *ContextBuilder[T] {
-	childBase := &Builder{
-		router: b.base.router,
-		prefix: append([]string{}, b.base.prefix...),
-		mws:    append(append([]Middleware{}, b.base.mws...), mws...),
+	return &ContextBuilder[T]{
+		base:     b.base.With(mws...),
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: b.resolved,
 	}
+}
+
+// WithResolved adds middleware that runs after the typed context T is
+// resolved, around the handler call, for all routes defined in the
+// returned typed builder. Unlike With, whose middleware runs before
+// resolution, it only runs when resolution succeeded, so FromContext
+// always finds the value, e.g. for auth checks on it. It applies
+// to handlers of this builder's T only, not to child layers derived with
+// WithChildContext.
+//
+// Example:
+//
+//	app.WithResolved(func(next clir.Handler) clir.Handler {
+//	    return func(req *clir.Request) error {
+//	        app, _ := clir.FromContext[AppCtx](req)
+//	        if !app.User.Admin {
+//	            return errors.New("admin only")
+//	        }
+//	        return next(req)
+//	    }
+//	}).Handle("users purge", "Purge users", purgeUsers)
+func (b *ContextBuilder[T]) WithResolved(mws ...Middleware) *ContextBuilder[T] {
+	return &ContextBuilder[T]{
+		base:     b.base,
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: append(slices.Clone(b.resolved), mws...),
+	}
+}
+
+// Timeout sets a default deadline for all routes defined in the returned
+// typed builder. See Builder.Timeout.
+func (b *ContextBuilder[T]) Timeout(d time.Duration) *ContextBuilder[T] {
+	return &ContextBuilder[T]{
+		base:     b.base.Timeout(d),
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: b.resolved,
+	}
+}
+
+// Long sets the detailed help text for routes handled by the returned
+// typed builder. See Builder.Long.
+func (b *ContextBuilder[T]) Long(text string) *ContextBuilder[T] {
 	return &ContextBuilder[T]{
-		base:    childBase,
-		resolve: b.resolve,
+		base:     b.base.Long(text),
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: b.resolved,
 	}
 }
 
 // Handle registers a typed handler under the current prefix + path.
 //
 // The handler receives both the Request and the resolved context T.
-func (b *ContextBuilder[T]) Handle(path, desc string, h ContextHandler[T]) {
-	parts := strings.Fields(path)
-	full := append(append([]string{}, b.base.prefix...), parts...)
-	pattern := strings.Join(full, " ")
-
-	baseHandler := func(req *Request) error {
-		ctxObj, err := b.resolve(req)
-		if err != nil {
-			return err
+func (b *ContextBuilder[T]) Handle(path, desc string, h ContextHandler[T]) *CommandHandle {
+	if len(b.resolved) > 0 {
+		inner := h
+		var next Handler = func(req *Request) error {
+			v, _ := FromContext[T](req)
+			return inner(req, v)
 		}
-		return h(req, ctxObj)
+		for i := len(b.resolved) - 1; i >= 0; i-- {
+			next = b.resolved[i](next)
+		}
+		h = func(req *Request, _ T) error { return next(req) }
 	}
-
-	wrapped := baseHandler
-	for i := len(b.base.mws) - 1; i >= 0; i-- {
-		wrapped = b.base.mws[i](wrapped)
+	handler := WithContextHandler(b.resolve, h)
+	if inject := b.inject; len(inject) > 0 {
+		typed := handler
+		handler = func(req *Request) error {
+			for _, fn := range inject {
+				var err error
+				if req, err = fn(req); err != nil {
+					return err
+				}
+			}
+			return typed(req)
+		}
 	}
 
-	b.base.router.Handle(pattern, desc, wrapped)
+	hd := b.base.handle(path, desc, handler)
+	resolve := b.resolve
+	hd.update("Handle", func(rt *route) {
+		rt.lazy = map[any]func(*Request) (any, error){
+			resolvedKey[T]{}: func(req *Request) (any, error) {
+				v, err := resolve(req)
+				if err == nil {
+					err = validate(v)
+				}
+				return v, err
+			},
+		}
+	})
+	return hd
+}
+
+// HandleRaw registers an untyped handler under the current prefix + path.
+// The builder's middleware, timeout and help metadata apply, but the typed
+// context is not resolved. It eases migrating handlers one at a time; for
+// the opposite direction, WithContextHandler adapts a ContextHandler[T]
+// to a plain Handler.
+//
+// Example:
+//
+//	comp.HandleRaw("legacy", "Old-style command", func(req *clir.Request) error {
+//	    return legacy(req.Params["component"])
+//	})
+func (b *ContextBuilder[T]) HandleRaw(path, desc string, h Handler) *CommandHandle {
+	return b.base.handle(path, desc, h)
+}
+
+// ContextHandlerSpec is a path/description/handler tuple for
+// ContextBuilder.Handles.
+type ContextHandlerSpec[T any] struct {
+	Path    string
+	Desc    string
+	Handler ContextHandler[T]
+}
+
+// Handles registers several typed handlers under the current prefix, in
+// order, as if Handle were called for each spec.
+func (b *ContextBuilder[T]) Handles(specs ...ContextHandlerSpec[T]) {
+	for _, s := range specs {
+		b.Handle(s.Path, s.Desc, s.Handler)
+	}
 }
 
 // WithContext lifts an untyped Builder into a typed
@@ -473,12 +1826,14 @@ func (b *ContextBuilder[T]) Handle(path, desc string, h ContextHandler[T]) {
 func WithContext[T any](b *Builder, resolve Resolver[T]) *ContextBuilder[T] {
 	return &ContextBuilder[T]{
 		base:    b,
-		resolve: resolve,
+		resolve: memoize(typeName[T](), resolve),
 	}
 }
 
 // WithChildContext derives a new typed context U from the parent
 // typed context T and the Request, for an existing typed builder.
+// Each layer runs only while the Request's context is live; once it is
+// cancelled or past its deadline, resolution stops with its error.
 //
 // Example:
 //
@@ -492,18 +1847,99 @@ func WithChildContext[T any, U any](
 	resolve func(parent T, req *Request) (U, error),
 ) *ContextBuilder[U] {
 	return &ContextBuilder[U]{
-		base: b.base,
-		resolve: func(req *Request) (U, error) {
+		base:   b.base,
+		inject: b.inject,
+		resolve: memoize(typeName[U](), func(req *Request) (U, error) {
+			var zero U
+			if err := req.Context().Err(); err != nil {
+				return zero, err
+			}
 			parent, err := b.resolve(req)
 			if err != nil {
-				var zero U
+				return zero, err
+			}
+			if err := req.Context().Err(); err != nil {
 				return zero, err
 			}
 			return resolve(parent, req)
-		},
+		}),
+	}
+}
+
+// resolverMemo holds per-Request resolver results, keyed by the identity
+// of the memoized resolver.
+type resolverMemo struct {
+	mu      sync.Mutex
+	results map[*memoKey]memoResult
+}
+
+type memoKey struct {
+	name string // resolved type, shown by TraceResolvers
+}
+
+type memoResult struct {
+	val any
+	err error
+}
+
+// memoize wraps resolve so that it runs at most once per Request, even
+// when several layers (or several lookups) depend on it. Errors are
+// cached too. Requests without a memo (not created by Run) are resolved
+// directly every time.
+//
+// name identifies the resolver in TraceResolvers output.
+func memoize[T any](name string, resolve Resolver[T]) Resolver[T] {
+	key := &memoKey{name: name}
+	return func(req *Request) (T, error) {
+		m := req.memo
+		if m == nil {
+			return traced(key.name, resolve, req)
+		}
+
+		m.mu.Lock()
+		if res, ok := m.results[key]; ok {
+			m.mu.Unlock()
+			traceMemoized(req, key.name)
+			v, _ := res.val.(T)
+			return v, res.err
+		}
+		m.mu.Unlock()
+
+		v, err := traced(key.name, resolve, req)
+
+		m.mu.Lock()
+		if m.results == nil {
+			m.results = map[*memoKey]memoResult{}
+		}
+		m.results[key] = memoResult{val: v, err: err}
+		m.mu.Unlock()
+		return v, err
 	}
 }
 
+// Validator is implemented by typed contexts that need a validation
+// phase. After a typed context is resolved, and before the handler runs,
+// Validate is called if the resolved value implements Validator; an
+// error aborts the invocation. Values that don't implement it are passed
+// through unchanged, and so is a nil pointer, even if its type
+// implements Validator.
+type Validator interface {
+	Validate() error
+}
+
+// validate calls v.Validate if v implements Validator and isn't a nil
+// pointer.
+func validate(v any) error {
+	vv, ok := v.(Validator)
+	if !ok {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil
+	}
+	return vv.Validate()
+}
+
 type ParentChild[T any, U any] struct {
 	parent T
 	child  U
@@ -512,8 +1948,9 @@ type ParentChild[T any, U any] struct {
 func (pc ParentChild[T, U]) Parent() T { return pc.parent }
 func (pc ParentChild[T, U]) Child() U  { return pc.child }
 
-// This doesn't need validation. The resolver can return an error for that.
-// func (pc ParentChild[T, U]) Validate() error { return nil }
+// Validate validates the child if it implements Validator, so cross-field
+// invariants between the resolved parent and child can be enforced there.
+func (pc ParentChild[T, U]) Validate() error { return validate(pc.child) }
 
 func WithParentChildContext[T any, U any](
 	b *ContextBuilder[T],
@@ -549,6 +1986,134 @@ func WithContextHandler[T any](resolve Resolver[T], h ContextHandler[T]) Handler
 		if err != nil {
 			return err
 		}
+		if err := validate(ctxObj); err != nil {
+			return err
+		}
+		req = req.WithContext(context.WithValue(req.Context(), resolvedKey[T]{}, ctxObj))
 		return h(req, ctxObj)
 	}
 }
+
+// resolvedKey is the context key under which a resolved typed context
+// of type T is stored. Each T gets a distinct key type.
+type resolvedKey[T any] struct{}
+
+// FromContext returns the typed context T resolved for this Request.
+// Typed handlers (ContextBuilder.Handle, WithContextHandler) store the
+// resolved value in the Request's context before calling the handler, so
+// helpers called with the Request can retrieve it without it being passed
+// explicitly. The resolver is not run again.
+//
+// For routes registered with ContextBuilder.Handle, FromContext also
+// works before the handler runs, in Before hooks and in middleware added
+// with With: the first call resolves (and validates) T for the Request,
+// and the handler gets the memoized result. It reports false if
+// resolution fails; the handler then returns the error as usual.
+//
+// Example:
+//
+//	func logComponent(req *clir.Request) {
+//	    if c, ok := clir.FromContext[*component.Adapter](req); ok {
+//	        log.Printf("component: %s", c.Name)
+//	    }
+//	}
+func FromContext[T any](req *Request) (T, bool) {
+	if v, ok := ContextValue[T](req.Context()); ok || req.route == nil {
+		return v, ok
+	}
+	resolve := req.route.lazy[resolvedKey[T]{}]
+	if resolve == nil {
+		var zero T
+		return zero, false
+	}
+	v, err := resolve(req)
+	if err != nil {
+		var zero T
+		return zero, false
+	}
+	t, ok := v.(T)
+	return t, ok
+}
+
+// ContextValue returns the typed context T stored in ctx by a typed
+// handler or a WithContextValue layer. It is the context.Context
+// counterpart of FromContext, for code that only receives a ctx.
+func ContextValue[T any](ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(resolvedKey[T]{}).(T)
+	return v, ok
+}
+
+// WithContextValue is like WithContext, but also stores the resolved T in
+// the request context for every handler registered below it, including
+// handlers of child layers derived with WithChildContext. Library code
+// that receives only a context.Context can retrieve it with
+// ContextValue[T]. The resolver still runs at most once per Request.
+//
+// Example:
+//
+//	app := clir.WithContextValue(b, resolveApp)
+//	app.Route("comp <component>", func(b *clir.ContextBuilder[AppCtx]) {
+//	    comp := clir.WithChildContext(b, resolveComponent)
+//	    comp.Handle("build", "Build", func(req *clir.Request, c *Component) error {
+//	        return builder.Run(req.Context()) // can call clir.ContextValue[AppCtx](ctx)
+//	    })
+//	})
+func WithContextValue[T any](b *Builder, resolve Resolver[T]) *ContextBuilder[T] {
+	cb := WithContext(b, resolve)
+	resolveMemo := cb.resolve
+	cb.inject = []injector{func(req *Request) (*Request, error) {
+		v, err := resolveMemo(req)
+		if err != nil {
+			return nil, err
+		}
+		return req.WithContext(context.WithValue(req.Context(), resolvedKey[T]{}, v)), nil
+	}}
+	return cb
+}
+
+// resolvedRequest is the memoized result of a RequestResolver.
+type resolvedRequest[T any] struct {
+	val T
+	req *Request
+}
+
+// WithRequestContext is like WithContext for a RequestResolver: the
+// Request it returns is the one passed to handlers registered below it,
+// including those of child layers derived with WithChildContext, so
+// values it puts in the context reach them. Middleware added with the
+// Builder runs before resolution and sees the original Request. The
+// resolver still runs at most once per Request.
+//
+// Example:
+//
+//	resolveUser := func(req *clir.Request) (User, *clir.Request, error) {
+//	    u, token, err := login(req.Context())
+//	    if err != nil {
+//	        return User{}, nil, err
+//	    }
+//	    return u, req.WithContext(auth.WithToken(req.Context(), token)), nil
+//	}
+//	users := clir.WithRequestContext(b, resolveUser)
+func WithRequestContext[T any](b *Builder, resolve RequestResolver[T]) *ContextBuilder[T] {
+	resolveMemo := memoize(typeName[T](), func(req *Request) (resolvedRequest[T], error) {
+		v, out, err := resolve(req)
+		return resolvedRequest[T]{v, out}, err
+	})
+	return &ContextBuilder[T]{
+		base: b,
+		resolve: func(req *Request) (T, error) {
+			res, err := resolveMemo(req)
+			return res.val, err
+		},
+		inject: []injector{func(req *Request) (*Request, error) {
+			res, err := resolveMemo(req)
+			if err != nil {
+				return nil, err
+			}
+			if res.req == nil {
+				return req, nil
+			}
+			return res.req, nil
+		}},
+	}
+}
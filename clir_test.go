@@ -5,8 +5,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // --- Helpers for tests ---
@@ -115,6 +120,287 @@ func TestRouter_Run_FirstMatchWins(t *testing.T) {
 	}
 }
 
+// --- Lifecycle hook tests ---
+
+func TestRouter_BeforeAfter_RunOutsideMiddleware(t *testing.T) {
+	r := New()
+
+	var steps []string
+
+	r.Before(func(req *Request) error {
+		steps = append(steps, "before")
+		return nil
+	})
+	r.After(func(req *Request, err error) error {
+		steps = append(steps, "after")
+		return err
+	})
+
+	mw := func(next Handler) Handler {
+		return func(req *Request) error {
+			steps = append(steps, "mw")
+			return next(req)
+		}
+	}
+
+	r.Routes(func(b *Builder) {
+		b.With(mw).Handle("do", "Do something", func(req *Request) error {
+			steps = append(steps, "handler")
+			return nil
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"do"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	want := []string{"before", "mw", "handler", "after"}
+	if fmt.Sprint(steps) != fmt.Sprint(want) {
+		t.Fatalf("unexpected hook order: got %v, want %v", steps, want)
+	}
+}
+
+func TestRouter_Before_AbortsHandler(t *testing.T) {
+	r := New()
+
+	r.Before(func(req *Request) error {
+		return errors.New("denied")
+	})
+
+	var afterErr error
+	r.After(func(req *Request, err error) error {
+		afterErr = err
+		return err
+	})
+
+	r.Handle("do", "Do something", func(req *Request) error {
+		t.Fatal("handler should not be called when Before fails")
+		return nil
+	})
+
+	err := r.Run(context.Background(), []string{"do"})
+	if err == nil || err.Error() != "denied" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if afterErr == nil || afterErr.Error() != "denied" {
+		t.Fatalf("After did not see Before error: %v", afterErr)
+	}
+}
+
+func TestRouter_After_TransformsError(t *testing.T) {
+	r := New()
+
+	r.After(func(req *Request, err error) error {
+		if err != nil {
+			return fmt.Errorf("wrapped: %w", err)
+		}
+		return nil
+	})
+
+	r.Handle("fail", "Fail", func(req *Request) error {
+		return errors.New("boom")
+	})
+
+	err := r.Run(context.Background(), []string{"fail"})
+	if err == nil || err.Error() != "wrapped: boom" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRouter_BeforeAfter_NotRunWithoutMatch(t *testing.T) {
+	r := New()
+
+	var ran bool
+	r.Before(func(req *Request) error { ran = true; return nil })
+	r.After(func(req *Request, err error) error { ran = true; return err })
+
+	if err := r.Run(context.Background(), []string{"nope"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if ran {
+		t.Fatal("hooks should not run when nothing matched")
+	}
+}
+
+// --- Registration tests ---
+
+func TestRouter_Handle_ConcurrentRegistration(t *testing.T) {
+	r := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.Handle(fmt.Sprintf("cmd%d", i), "Command", func(req *Request) error { return nil })
+		}(i)
+	}
+	wg.Wait()
+
+	if len(r.routes) != 50 {
+		t.Fatalf("expected 50 routes, got %d", len(r.routes))
+	}
+	if err := r.Run(context.Background(), []string{"cmd42"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+}
+
+func TestRouter_Freeze_PanicsOnLateRegistration(t *testing.T) {
+	r := New()
+	r.Handle("cmd", "Command", func(req *Request) error { return nil })
+	r.Freeze()
+
+	if !r.Frozen() {
+		t.Fatal("Frozen returned false after Freeze")
+	}
+	if err := r.Run(context.Background(), []string{"cmd"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic on Handle after Freeze")
+		}
+	}()
+	r.Handle("late", "Late command", func(req *Request) error { return nil })
+}
+
+func TestRouter_Freeze_PanicsOnLateSetters(t *testing.T) {
+	r := New()
+	r.Handle("cmd", "Command", func(req *Request) error { return nil })
+	r.Freeze()
+
+	for name, set := range map[string]func(){
+		"SetBaseContext":         func() { r.SetBaseContext(context.Background()) },
+		"Default":                func() { r.Default("cmd") },
+		"SetMaxArgs":             func() { r.SetMaxArgs(8) },
+		"SetGlobalFlags":         func() { r.SetGlobalFlags() },
+		"SetOutput":              func() { r.SetOutput(io.Discard, io.Discard) },
+		"SetColor":               func() { r.SetColor(ColorNever) },
+		"EnableDryRun":           func() { r.EnableDryRun(true) },
+		"EnableHelpFlag":         func() { r.EnableHelpFlag(true) },
+		"EnableGroupHelp":        func() { r.EnableGroupHelp(true) },
+		"SetHelpOrder":           func() { r.SetHelpOrder(HelpOrderAlphabetical) },
+		"SetHelpMaxPatternWidth": func() { r.SetHelpMaxPatternWidth(20) },
+		"SetName":                func() { r.SetName("mytool") },
+		"SetTokenizer":           func() { r.SetTokenizer(nil) },
+		"EnableMatchCache":       func() { r.EnableMatchCache(16) },
+		"SetInlineMountHelp":     func() { r.SetInlineMountHelp(true) },
+		"SetNoMatchMessage":      func() { r.SetNoMatchMessage(nil) },
+		"SetStripProgramName":    func() { r.SetStripProgramName(true) },
+		"EnableResponseFiles":    func() { r.EnableResponseFiles(true) },
+		"SetSession":             func() { r.SetSession(NewSession()) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected %s after Freeze to panic", name)
+				}
+			}()
+			set()
+		}()
+	}
+}
+
+func TestRouter_Handle_PanicsOnDuplicateParam(t *testing.T) {
+	noop := func(*Request) error { return nil }
+	for _, pattern := range []string{
+		"copy <name> <name>",
+		"set <k>=<v> <k>",
+		"tag <t> add <t+>",
+	} {
+		func() {
+			defer func() {
+				if msg, _ := recover().(string); !strings.Contains(msg, "duplicate param") {
+					t.Errorf("Handle(%q) panic = %q, want a duplicate param panic", pattern, msg)
+				}
+			}()
+			New().Handle(pattern, "Broken", noop)
+		}()
+	}
+
+	r := New()
+	r.Routes(func(b *Builder) {
+		defer func() {
+			want := `clir: duplicate param <id> in pattern "users <id> groups <id>": <id> is already captured by the enclosing prefix "users <id>"`
+			if msg, _ := recover().(string); msg != want {
+				t.Errorf("unexpected panic for a duplicate from the prefix:\n%s\nwant:\n%s", msg, want)
+			}
+		}()
+		b.Route("users <id>", func(b *Builder) {
+			b.Handle("groups <id>", "Broken", noop)
+		})
+	})
+}
+
+func TestRouter_Run_TiesResolveToEarliestRegistered(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		argv     []string
+	}{
+		{"identical literals", []string{"deploy prod", "deploy prod"}, []string{"deploy", "prod"}},
+		{"same shape, different param names", []string{"users <id>", "users <name>"}, []string{"users", "42"}},
+		{"override before general route", []string{"comp <c> build", "comp <component> build"}, []string{"comp", "api", "build", "-v"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, order := range [][]int{{0, 1}, {1, 0}} {
+				r := New()
+
+				var got string
+				for _, i := range order {
+					p := tt.patterns[i]
+					desc := fmt.Sprint(i)
+					r.Handle(p, desc, func(req *Request) error {
+						got = desc
+						return nil
+					})
+				}
+
+				if err := r.Run(context.Background(), tt.argv); err != nil {
+					t.Fatalf("Run returned error: %v", err)
+				}
+				if want := fmt.Sprint(order[0]); got != want {
+					t.Fatalf("order %v: got route %s, want earliest registered %s", order, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRequest_Pattern(t *testing.T) {
+	r := New()
+
+	var fromMiddleware, fromHandler string
+	r.Routes(func(b *Builder) {
+		b.With(func(next Handler) Handler {
+			return func(req *Request) error {
+				fromMiddleware = req.Pattern()
+				return next(req)
+			}
+		}).Route("1 comp <component>", func(b *Builder) {
+			b.Handle("image build", "Build images", func(req *Request) error {
+				fromHandler = req.Pattern()
+				return nil
+			})
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"comp", "api", "image", "build", "--push"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	want := "comp <component> image build"
+	if fromMiddleware != want || fromHandler != want {
+		t.Fatalf("unexpected pattern: middleware=%q handler=%q", fromMiddleware, fromHandler)
+	}
+	if got := (&Request{}).Pattern(); got != "" {
+		t.Fatalf("Pattern on a bare Request = %q, want empty", got)
+	}
+}
+
 // --- Request context tests ---
 
 func TestRequest_Context_DefaultBackgroundWhenNil(t *testing.T) {
@@ -137,6 +423,45 @@ func TestRequest_Context_DefaultBackgroundWhenNil(t *testing.T) {
 	}
 }
 
+// --- Output tests ---
+
+func TestRouter_SetOutput(t *testing.T) {
+	r := New()
+
+	r.Handle("hello", "Say hello", func(req *Request) error {
+		fmt.Fprintln(req.Stdout, "hello world")
+		fmt.Fprintln(req.Stderr, "warning")
+		return nil
+	})
+
+	var out, errOut bytes.Buffer
+	r.SetOutput(&out, &errOut)
+
+	if err := r.Run(context.Background(), []string{"hello"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if out.String() != "hello world\n" || errOut.String() != "warning\n" {
+		t.Fatalf("unexpected output: stdout=%q stderr=%q", out.String(), errOut.String())
+	}
+}
+
+func TestRequest_Output_DefaultsToOS(t *testing.T) {
+	r := New()
+
+	var req *Request
+	r.Handle("cmd", "Test", func(rq *Request) error {
+		req = rq
+		return nil
+	})
+
+	if err := r.Run(context.Background(), []string{"cmd"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if req.Stdout != os.Stdout || req.Stderr != os.Stderr {
+		t.Fatal("expected Stdout/Stderr to default to os.Stdout/os.Stderr")
+	}
+}
+
 // --- PrintHelp tests ---
 
 func TestRouter_PrintHelp_NoCommands(t *testing.T) {
@@ -224,6 +549,68 @@ func TestBuilder_WithMiddleware_Order(t *testing.T) {
 	}
 }
 
+// --- Timeout tests ---
+
+func TestBuilder_Timeout_SetsDeadline(t *testing.T) {
+	r := New()
+
+	deadlines := map[string]time.Duration{}
+	record := func(name string) Handler {
+		return func(req *Request) error {
+			if dl, ok := req.Context().Deadline(); ok {
+				deadlines[name] = time.Until(dl)
+			}
+			return nil
+		}
+	}
+
+	r.Routes(func(b *Builder) {
+		b.Timeout(time.Minute).Route("remote", func(b *Builder) {
+			b.Handle("sync", "Sync", record("sync"))
+		})
+		b.Handle("local", "Local", record("local"))
+	})
+
+	if err := r.Run(context.Background(), []string{"remote", "sync"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if d := deadlines["sync"]; d <= 0 || d > time.Minute {
+		t.Fatalf("unexpected deadline for sync: %v", d)
+	}
+
+	if err := r.Run(context.Background(), []string{"remote", "sync", "--timeout", "2h"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if d := deadlines["sync"]; d <= time.Hour {
+		t.Fatalf("--timeout did not override the default: %v", d)
+	}
+
+	if err := r.Run(context.Background(), []string{"local"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if _, ok := deadlines["local"]; ok {
+		t.Fatal("route without timeout should have no deadline")
+	}
+}
+
+func TestBuilder_Timeout_InvalidFlag(t *testing.T) {
+	r := New()
+
+	r.Routes(func(b *Builder) {
+		b.Timeout(time.Second).Handle("sync", "Sync", func(req *Request) error {
+			t.Fatal("handler should not run with an invalid --timeout")
+			return nil
+		})
+	})
+
+	for _, v := range []string{"soon", "0", "0s", "-5s"} {
+		err := r.Run(context.Background(), []string{"sync", "--timeout=" + v})
+		if err == nil || !strings.Contains(err.Error(), "invalid --timeout") {
+			t.Fatalf("--timeout=%s: unexpected error: %v", v, err)
+		}
+	}
+}
+
 // --- Builder prefix tests ---
 
 func TestBuilder_RoutePrefixesAndHandle(t *testing.T) {
@@ -254,6 +641,49 @@ func TestBuilder_RoutePrefixesAndHandle(t *testing.T) {
 	}
 }
 
+func TestBuilder_Handles_RegistersInOrder(t *testing.T) {
+	r := New()
+
+	var got []string
+	record := func(name string) Handler {
+		return func(req *Request) error {
+			got = append(got, name)
+			return nil
+		}
+	}
+
+	r.Routes(func(b *Builder) {
+		b.Route("image", func(b *Builder) {
+			b.Handles(
+				HandlerSpec{"build", "Build images", record("build")},
+				HandlerSpec{"<name>", "Show image", record("show")},
+				HandlerSpec{"<other>", "Shadowed", record("shadowed")},
+			)
+		})
+		WithContext(b, func(req *Request) (appCtx, error) {
+			return appCtx{Name: "cli-app"}, nil
+		}).Handles(
+			ContextHandlerSpec[appCtx]{"ping", "Ping", func(req *Request, a appCtx) error {
+				got = append(got, "ping:"+a.Name)
+				return nil
+			}},
+		)
+	})
+
+	for _, argv := range [][]string{{"image", "build"}, {"image", "web"}, {"ping"}} {
+		if err := r.Run(context.Background(), argv); err != nil {
+			t.Fatalf("Run(%v) returned error: %v", argv, err)
+		}
+	}
+
+	if fmt.Sprint(got) != "[build show ping:cli-app]" {
+		t.Fatalf("unexpected calls: %v", got)
+	}
+	if len(r.routes) != 4 || r.routes[0].String() != "image build" || r.routes[2].desc != "Shadowed" {
+		t.Fatalf("unexpected registration order: %v", r.routes)
+	}
+}
+
 // --- Typed context tests ---
 
 func TestTypedContext_WithContextHandler_DirectRouterHandle(t *testing.T) {
@@ -398,67 +828,338 @@ func TestTypedContext_LayeredContexts_WithChildContext(t *testing.T) {
 	}
 }
 
-// --- Example-style tests (documentation via go test / go doc) ---
-
-func ExampleRouter_basic() {
+func TestTypedContext_WithChildContext_StopsWhenCancelled(t *testing.T) {
 	r := New()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The parent layer cancels the request, as a deadline might.
+	resolveApp := func(req *Request) (appCtx, error) {
+		cancel()
+		return appCtx{Name: "cli-app"}, nil
+	}
+	childRan := false
+	resolveComponent := func(app appCtx, req *Request) (componentCtx, error) {
+		childRan = true
+		return componentCtx{App: app}, nil
+	}
 
 	r.Routes(func(b *Builder) {
-		b.Handle("hello", "Say hello", func(req *Request) error {
-			fmt.Println("hello world")
+		compB := WithChildContext(WithContext(b, resolveApp), resolveComponent)
+		compB.Handle("build", "Build images", func(*Request, componentCtx) error {
+			t.Fatal("handler should not run")
 			return nil
 		})
 	})
 
-	_ = r.Run(context.Background(), []string{"hello"})
-	// Output:
-	// hello world
+	err := r.Run(ctx, []string{"build"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if childRan {
+		t.Fatal("child resolver ran after cancellation")
+	}
 }
 
-func ExampleContextBuilder_layered() {
+func TestTypedContext_FromContext(t *testing.T) {
 	r := New()
 
-	// Root resolver for the app context.
+	resolves := 0
 	resolveApp := func(req *Request) (appCtx, error) {
+		resolves++
 		return appCtx{Name: "cli-app"}, nil
 	}
 
-	// Child resolver for component context.
-	resolveComponent := func(app appCtx, req *Request) (componentCtx, error) {
-		return componentCtx{
-			App:  app,
-			Name: req.Params["component"],
-		}, nil
+	// A helper that only has the Request, not the typed argument.
+	appName := func(req *Request) string {
+		app, ok := FromContext[appCtx](req)
+		if !ok {
+			return "<none>"
+		}
+		return app.Name
 	}
 
+	var got string
 	r.Routes(func(b *Builder) {
-		// Lift to a typed app context builder.
-		appB := WithContext(b, resolveApp)
-
-		appB.Route("comp <component>", func(b *ContextBuilder[appCtx]) {
-			// Derive component context from app context.
-			compB := WithChildContext(b, resolveComponent)
-
-			compB.Route("image", func(b *ContextBuilder[componentCtx]) {
-				b.Handle("build", "Build images", func(req *Request, ctx componentCtx) error {
-					fmt.Printf("app=%s component=%s\n", ctx.App.Name, ctx.Name)
-					return nil
-				})
-			})
+		WithContext(b, resolveApp).Handle("ping", "Ping", func(req *Request, app appCtx) error {
+			got = appName(req)
+			return nil
 		})
 	})
 
-	_ = r.Run(context.Background(), []string{"comp", "cv-server", "image", "build"})
-	// Output:
-	// app=cli-app component=cv-server
+	if err := r.Run(context.Background(), []string{"ping"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got != "cli-app" {
+		t.Fatalf("FromContext returned %q", got)
+	}
+	if resolves != 1 {
+		t.Fatalf("resolver ran %d times, want 1", resolves)
+	}
+	if _, ok := FromContext[componentCtx](&Request{}); ok {
+		t.Fatal("FromContext should report false when nothing was resolved")
+	}
 }
 
-func TestRouter_bestMatch_RankingMatrix(t *testing.T) {
-	type tc struct {
-		name       string
-		routes     []string
-		argv       []string
-		wantRoute  string
+func TestTypedContext_ResolversRunOncePerRequest(t *testing.T) {
+	r := New()
+
+	calls := map[string]int{}
+	resolveApp := func(req *Request) (appCtx, error) {
+		calls["app"]++
+		return appCtx{Name: "cli-app"}, nil
+	}
+	resolveComponent := func(app appCtx, req *Request) (componentCtx, error) {
+		calls["component"]++
+		return componentCtx{App: app, Name: req.Params["component"]}, nil
+	}
+
+	var app *ContextBuilder[appCtx]
+	r.Routes(func(b *Builder) {
+		app = WithContext(b, resolveApp)
+		app.Route("comp <component>", func(b *ContextBuilder[appCtx]) {
+			comp := WithChildContext(b, resolveComponent)
+			task := WithChildContext(comp, func(c componentCtx, req *Request) (string, error) {
+				// Ask for the app context again from a deeper layer.
+				a, err := app.resolve(req)
+				return a.Name + "/" + c.Name, err
+			})
+			task.Handle("run", "Run", func(req *Request, s string) error {
+				if s != "cli-app/api" {
+					t.Fatalf("unexpected resolved value: %q", s)
+				}
+				return nil
+			})
+		})
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := r.Run(context.Background(), []string{"comp", "api", "run"}); err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+	}
+
+	if calls["app"] != 2 || calls["component"] != 2 {
+		t.Fatalf("expected each resolver to run once per request, got %v", calls)
+	}
+}
+
+func TestTypedContext_WithContextValue(t *testing.T) {
+	r := New()
+
+	resolves := 0
+	resolveApp := func(req *Request) (appCtx, error) {
+		resolves++
+		return appCtx{Name: "cli-app"}, nil
+	}
+
+	// Library code that only receives a context.Context.
+	appFromCtx := func(ctx context.Context) string {
+		app, _ := ContextValue[appCtx](ctx)
+		return app.Name
+	}
+
+	var got string
+	r.Routes(func(b *Builder) {
+		app := WithContextValue(b, resolveApp)
+		app.Route("comp <component>", func(b *ContextBuilder[appCtx]) {
+			comp := WithChildContext(b, func(app appCtx, req *Request) (componentCtx, error) {
+				return componentCtx{App: app, Name: req.Params["component"]}, nil
+			})
+			comp.Handle("build", "Build", func(req *Request, c componentCtx) error {
+				got = appFromCtx(req.Context()) + "/" + c.Name
+				return nil
+			})
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"comp", "api", "build"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got != "cli-app/api" {
+		t.Fatalf("unexpected value: %q", got)
+	}
+	if resolves != 1 {
+		t.Fatalf("resolver ran %d times, want 1", resolves)
+	}
+}
+
+type validatedCtx struct {
+	Name string
+}
+
+func (c *validatedCtx) Validate() error {
+	if c.Name == "" {
+		return errors.New("component name required")
+	}
+	return nil
+}
+
+func TestTypedContext_ValidateNilPointer(t *testing.T) {
+	r := New()
+	r.Routes(func(b *Builder) {
+		WithContext(b, func(*Request) (*validatedCtx, error) {
+			return nil, nil
+		}).Handle("noop", "No component", func(_ *Request, c *validatedCtx) error {
+			if c != nil {
+				t.Fatalf("expected a nil context, got %v", c)
+			}
+			return nil
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"noop"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+}
+
+func TestTypedContext_WithRequestContext(t *testing.T) {
+	type tokenKey struct{}
+	r := New()
+
+	resolved := 0
+	resolveApp := func(req *Request) (appCtx, *Request, error) {
+		resolved++
+		return appCtx{Name: "cli-app"}, req.WithContext(context.WithValue(req.Context(), tokenKey{}, "secret")), nil
+	}
+	resolveComponent := func(app appCtx, req *Request) (componentCtx, error) {
+		return componentCtx{App: app, Name: req.Params["component"]}, nil
+	}
+
+	var got []string
+	r.Routes(func(b *Builder) {
+		app := WithRequestContext(b, resolveApp)
+		app.Handle("whoami", "Show the token", func(req *Request, app appCtx) error {
+			got = append(got, app.Name+" "+req.Context().Value(tokenKey{}).(string))
+			return nil
+		})
+		app.Route("comp <component>", func(b *ContextBuilder[appCtx]) {
+			WithChildContext(b, resolveComponent).Handle("build", "Build", func(req *Request, c componentCtx) error {
+				got = append(got, c.Name+" "+req.Context().Value(tokenKey{}).(string))
+				return nil
+			})
+		})
+	})
+
+	for _, argv := range [][]string{{"whoami"}, {"comp", "api", "build"}} {
+		if err := r.Run(context.Background(), argv); err != nil {
+			t.Fatalf("Run(%v) returned error: %v", argv, err)
+		}
+	}
+	if fmt.Sprint(got) != "[cli-app secret api secret]" {
+		t.Fatalf("unexpected results: %v", got)
+	}
+	if resolved != 2 {
+		t.Fatalf("expected one resolution per Run, got %d", resolved)
+	}
+}
+
+func TestTypedContext_ValidateHook(t *testing.T) {
+	r := New()
+
+	resolveApp := func(req *Request) (appCtx, error) {
+		return appCtx{Name: "cli-app"}, nil
+	}
+
+	var handled []string
+	r.Routes(func(b *Builder) {
+		app := WithContext(b, resolveApp)
+		app.Route("comp <component>", func(b *ContextBuilder[appCtx]) {
+			pc := WithParentChildContext(b, func(app appCtx, req *Request) (*validatedCtx, error) {
+				name := req.Params["component"]
+				if name == "-" {
+					name = ""
+				}
+				return &validatedCtx{Name: name}, nil
+			})
+			pc.Handle("build", "Build", func(req *Request, c ParentChild[appCtx, *validatedCtx]) error {
+				handled = append(handled, c.Parent().Name+"/"+c.Child().Name)
+				return nil
+			})
+		})
+		// appCtx doesn't implement Validator: no-op.
+		app.Handle("ping", "Ping", func(req *Request, a appCtx) error {
+			handled = append(handled, "ping")
+			return nil
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"comp", "api", "build"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	err := r.Run(context.Background(), []string{"comp", "-", "build"})
+	if err == nil || err.Error() != "component name required" {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if err := r.Run(context.Background(), []string{"ping"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if fmt.Sprint(handled) != "[cli-app/api ping]" {
+		t.Fatalf("unexpected handled: %v", handled)
+	}
+}
+
+// --- Example-style tests (documentation via go test / go doc) ---
+
+func ExampleRouter_basic() {
+	r := New()
+
+	r.Routes(func(b *Builder) {
+		b.Handle("hello", "Say hello", func(req *Request) error {
+			fmt.Println("hello world")
+			return nil
+		})
+	})
+
+	_ = r.Run(context.Background(), []string{"hello"})
+	// Output:
+	// hello world
+}
+
+func ExampleContextBuilder_layered() {
+	r := New()
+
+	// Root resolver for the app context.
+	resolveApp := func(req *Request) (appCtx, error) {
+		return appCtx{Name: "cli-app"}, nil
+	}
+
+	// Child resolver for component context.
+	resolveComponent := func(app appCtx, req *Request) (componentCtx, error) {
+		return componentCtx{
+			App:  app,
+			Name: req.Params["component"],
+		}, nil
+	}
+
+	r.Routes(func(b *Builder) {
+		// Lift to a typed app context builder.
+		appB := WithContext(b, resolveApp)
+
+		appB.Route("comp <component>", func(b *ContextBuilder[appCtx]) {
+			// Derive component context from app context.
+			compB := WithChildContext(b, resolveComponent)
+
+			compB.Route("image", func(b *ContextBuilder[componentCtx]) {
+				b.Handle("build", "Build images", func(req *Request, ctx componentCtx) error {
+					fmt.Printf("app=%s component=%s\n", ctx.App.Name, ctx.Name)
+					return nil
+				})
+			})
+		})
+	})
+
+	_ = r.Run(context.Background(), []string{"comp", "cv-server", "image", "build"})
+	// Output:
+	// app=cli-app component=cv-server
+}
+
+func TestRouter_bestMatch_RankingMatrix(t *testing.T) {
+	type tc struct {
+		name       string
+		routes     []string
+		argv       []string
+		wantRoute  string
 		wantParams Params
 		wantExtra  []string
 		wantOK     bool
@@ -606,3 +1307,583 @@ func TestRouter_bestMatch_RankingMatrix(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_RestSegment_CapturesVerbatim(t *testing.T) {
+	r := New()
+	r.SetGlobalFlags(FlagSpec{Name: "verbose", Short: "v", Bool: true})
+	r.EnableDryRun(true)
+
+	var got *Request
+	r.Handle("exec <cmd...>", "Run a command", func(req *Request) error {
+		got = req
+		return nil
+	})
+
+	argv := []string{"-v", "exec", "ls", "-v", "--dry-run", "--", "x"}
+	if err := r.Run(context.Background(), argv); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got == nil {
+		t.Fatal("handler did not run")
+	}
+	if !got.Globals.Bool("verbose") {
+		t.Fatal("expected leading -v to be parsed as a global flag")
+	}
+	if fmt.Sprint(got.ParamLists["cmd"]) != "[ls -v --dry-run -- x]" {
+		t.Fatalf("unexpected rest capture: %v", got.ParamLists)
+	}
+	if len(got.Extra) != 0 || len(got.Params) != 0 {
+		t.Fatalf("expected no extra or params, got extra=%v params=%v", got.Extra, got.Params)
+	}
+	if got.Pattern() != "exec <cmd...>" {
+		t.Fatalf("unexpected pattern: %q", got.Pattern())
+	}
+
+	if err := r.Run(context.Background(), []string{"exec"}); err == nil {
+		t.Fatal("expected a rest segment to require at least one token")
+	}
+}
+
+func TestRouter_RestSegment_Ranking(t *testing.T) {
+	r := New()
+	r.Handle("exec <cmd...>", "Run a command", func(*Request) error { return nil })
+	r.Handle("exec ls <dir>", "List a directory", func(*Request) error { return nil })
+
+	for line, want := range map[string]string{
+		"exec ls /tmp":     "exec ls <dir>",
+		"exec ls /tmp -la": "exec ls <dir>",
+		"exec ls":          "exec <cmd...>",
+		"exec make all":    "exec <cmd...>",
+	} {
+		rt, ok := r.lookup(strings.Fields(line))
+		if !ok || rt.String() != want {
+			t.Errorf("lookup(%q) = %v, want %q", line, rt, want)
+		}
+	}
+}
+
+func TestRequest_Extra_PreservesOrder(t *testing.T) {
+	r := New()
+	r.SetGlobalFlags(FlagSpec{Name: "verbose", Short: "v", Bool: true})
+	var got *Request
+	h := func(req *Request) error {
+		got = req
+		return nil
+	}
+	r.Handle("run <task>", "Run a task", h)
+	r.Handle("tag add <tags+>", "Add tags", h)
+	r.Handle("exec <cmd...>", "Run a command", h)
+
+	tests := []struct {
+		argv  string
+		extra string
+	}{
+		{"run build --env a run --env b build -x", "[--env a run --env b build -x]"},
+		{"-v run build run build", "[run build]"},
+		{"run build", "[]"},
+		{"tag add a b --push c a", "[--push c a]"},
+		{"tag add a b", "[]"},
+		{"exec ls -la run", "[]"},
+		{"run build x -- y", "[x]"},
+	}
+	for _, tt := range tests {
+		got = nil
+		if err := r.Run(context.Background(), strings.Fields(tt.argv)); err != nil {
+			t.Fatalf("Run(%q) returned error: %v", tt.argv, err)
+		}
+		if fmt.Sprint(got.Extra) != tt.extra {
+			t.Errorf("Run(%q): Extra = %v, want %s", tt.argv, got.Extra, tt.extra)
+		}
+		// Extra is always a suffix of the matched part of Args.
+		args := got.Args
+		if i := slices.Index(args, "--"); i >= 0 {
+			args = args[:i]
+		}
+		if n := len(got.Extra); n > 0 && fmt.Sprint(args[len(args)-n:]) != fmt.Sprint(got.Extra) {
+			t.Errorf("Run(%q): Extra %v is not the tail of %v", tt.argv, got.Extra, args)
+		}
+	}
+}
+
+func TestRequest_Timing(t *testing.T) {
+	child := New()
+	var got *Request
+	child.Handle("list", "List plugins", func(req *Request) error {
+		got = req
+		return nil
+	})
+	var parent *Request
+	r := New()
+	r.Before(func(req *Request) error {
+		parent = req
+		return nil
+	})
+	r.Mount("plugin", child)
+
+	before := time.Now()
+	if err := r.Run(context.Background(), []string{"plugin", "list"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got.StartedAt.Before(before) || got.StartedAt.After(time.Now()) {
+		t.Fatalf("unexpected StartedAt: %v", got.StartedAt)
+	}
+	if !got.StartedAt.Equal(parent.StartedAt) {
+		t.Fatalf("mounted request should keep the parent's start: %v vs %v", got.StartedAt, parent.StartedAt)
+	}
+	if got.MatchDuration < 0 || got.MatchDuration > got.Elapsed() {
+		t.Fatalf("unexpected MatchDuration %v (elapsed %v)", got.MatchDuration, got.Elapsed())
+	}
+	if (&Request{}).Elapsed() != 0 {
+		t.Fatal("Elapsed should be 0 for Requests not created by Run")
+	}
+}
+
+func TestRouter_PassThrough(t *testing.T) {
+	r := New()
+	var got *Request
+	h := func(req *Request) error {
+		got = req
+		return nil
+	}
+	r.Handle("run <task>", "Run a task", h)
+	r.Handle("run <task> now", "Run a task now", h)
+	r.Handle("exec <cmd...>", "Run a command", h)
+
+	tests := []struct {
+		argv    string
+		pattern string
+		extra   string
+		pass    string
+		lists   string
+	}{
+		{"run build -v", "run <task>", "[-v]", "[]", "map[]"},
+		{"run build -v -- now -x", "run <task>", "[-v]", "[now -x]", "map[]"},
+		{"run build now --", "run <task> now", "[]", "[]", "map[]"},
+		{"exec ls -- -la", "exec <cmd...>", "[]", "[]", "map[cmd:[ls -- -la]]"},
+		{"exec -- ls -la", "exec <cmd...>", "[]", "[]", "map[cmd:[-- ls -la]]"},
+	}
+	for _, tt := range tests {
+		got = nil
+		if err := r.Run(context.Background(), strings.Fields(tt.argv)); err != nil {
+			t.Fatalf("Run(%q) returned error: %v", tt.argv, err)
+		}
+		if got.Pattern() != tt.pattern {
+			t.Errorf("Run(%q) matched %q, want %q", tt.argv, got.Pattern(), tt.pattern)
+		}
+		if fmt.Sprint(got.Extra) != tt.extra || fmt.Sprint(got.PassThrough) != tt.pass || fmt.Sprint(got.ParamLists) != tt.lists {
+			t.Errorf("Run(%q): extra=%v pass=%v lists=%v, want %s %s %s",
+				tt.argv, got.Extra, got.PassThrough, got.ParamLists, tt.extra, tt.pass, tt.lists)
+		}
+	}
+
+	if err := r.Run(context.Background(), []string{"run", "--", "build"}); err == nil {
+		t.Fatal("tokens after -- should not be matched")
+	}
+}
+
+func TestRouter_PassThrough_Mount(t *testing.T) {
+	child := New()
+	var got *Request
+	child.Handle("list", "List plugins", func(req *Request) error {
+		got = req
+		return nil
+	})
+	r := New()
+	r.Mount("plugin", child)
+
+	if err := r.Run(context.Background(), strings.Fields("plugin list --all -- x")); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if fmt.Sprint(got.Extra) != "[--all]" || fmt.Sprint(got.PassThrough) != "[x]" {
+		t.Fatalf("unexpected extra=%v pass=%v", got.Extra, got.PassThrough)
+	}
+}
+
+func TestRouter_RunArgs(t *testing.T) {
+	saved := os.Args
+	t.Cleanup(func() { os.Args = saved })
+	os.Args = []string{"/usr/local/bin/mytool", "comp", "api", "build", "--push"}
+
+	r := New()
+	var got *Request
+	r.Handle("comp <component> build", "Build a component", func(req *Request) error {
+		got = req
+		return nil
+	})
+
+	if err := r.RunArgs(context.Background()); err != nil {
+		t.Fatalf("RunArgs returned error: %v", err)
+	}
+	if got.Params["component"] != "api" || fmt.Sprint(got.Args) != "[comp api build --push]" {
+		t.Fatalf("unexpected request: params=%v args=%v", got.Params, got.Args)
+	}
+}
+
+func TestRouter_PreRun(t *testing.T) {
+	r := New()
+
+	var ran []string
+	r.Handle("build", "Build", func(req *Request) error {
+		ran = append(ran, "build "+strings.Join(req.Extra, " "))
+		return nil
+	})
+
+	// Aliases expand before matching.
+	r.PreRun(func(argv []string) ([]string, bool, error) {
+		if len(argv) > 0 && argv[0] == "b" {
+			return append([]string{"build"}, argv[1:]...), false, nil
+		}
+		return argv, false, nil
+	})
+	// --version is handled anywhere, without matching.
+	r.PreRun(func(argv []string) ([]string, bool, error) {
+		if slices.Contains(argv, "--version") {
+			ran = append(ran, "version")
+			return nil, true, nil
+		}
+		return argv, false, nil
+	})
+	r.PreRun(func(argv []string) ([]string, bool, error) {
+		if slices.Contains(argv, "--forbidden") {
+			return nil, false, errors.New("forbidden")
+		}
+		return argv, false, nil
+	})
+
+	for _, argv := range [][]string{{"b", "--fast"}, {"nope", "--version"}} {
+		if err := r.Run(context.Background(), argv); err != nil {
+			t.Fatalf("Run(%v) returned error: %v", argv, err)
+		}
+	}
+	if err := r.Run(context.Background(), []string{"build", "--forbidden"}); err == nil || err.Error() != "forbidden" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(ran) != "[build --fast version]" {
+		t.Fatalf("unexpected runs: %v", ran)
+	}
+}
+
+func TestRouter_Default(t *testing.T) {
+	r := New()
+	var ran string
+	record := func(name string) Handler {
+		return func(req *Request) error {
+			ran = strings.TrimSpace(name + " " + strings.Join(req.Extra, " "))
+			return nil
+		}
+	}
+	r.Handle("status", "Show status", record("status"))
+	r.Handle("log", "Show log", record("log"))
+	r.Handle("--version", "Show version", record("version"))
+	r.Default("status")
+
+	for argv, want := range map[string]string{
+		"":              "status",
+		"--short":       "status --short",
+		"status --long": "status --long",
+		"log":           "log",
+		"log --oneline": "log --oneline",
+		"--version":     "version", // an explicit match beats the default
+	} {
+		ran = ""
+		if err := r.Run(context.Background(), strings.Fields(argv)); err != nil || ran != want {
+			t.Errorf("Run(%q): ran=%q err=%v, want %q", argv, ran, err, want)
+		}
+	}
+
+	var noMatch *NoMatchError
+	if err := r.Run(context.Background(), []string{"stauts"}); !errors.As(err, &noMatch) {
+		t.Fatalf("expected a typo to fail with NoMatchError, got %v", err)
+	}
+
+	for _, command := range []string{"", "  "} {
+		r.Default("status")
+		r.Default(command)
+		if r.defaultArgv != nil {
+			t.Fatalf("Default(%q) left defaultArgv = %q", command, r.defaultArgv)
+		}
+		for _, argv := range [][]string{nil, {"--short"}} {
+			if err := r.Run(context.Background(), argv); !errors.As(err, &noMatch) {
+				t.Fatalf("Default(%q): Run(%q) = %v, want no match", command, argv, err)
+			}
+		}
+	}
+}
+
+func TestRouter_ListSegment(t *testing.T) {
+	r := New()
+	var got *Request
+	r.Handle("tag add <tags+>", "Add tags", func(req *Request) error {
+		got = req
+		return nil
+	})
+
+	if err := r.Run(context.Background(), []string{"tag", "add", "a", "b", "--push", "c"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if fmt.Sprint(got.ParamLists["tags"]) != "[a b]" || fmt.Sprint(got.Extra) != "[--push c]" {
+		t.Fatalf("unexpected capture: lists=%v extra=%v", got.ParamLists, got.Extra)
+	}
+	if got.Pattern() != "tag add <tags+>" {
+		t.Fatalf("unexpected pattern: %q", got.Pattern())
+	}
+
+	for _, argv := range [][]string{{"tag", "add"}, {"tag", "add", "--push"}, {"tag", "add", "--", "a"}} {
+		if err := r.Run(context.Background(), argv); err == nil {
+			t.Errorf("Run(%v) should need at least one non-flag token", argv)
+		}
+	}
+}
+
+func TestRequest_ParamIndex(t *testing.T) {
+	r := New()
+	r.SetGlobalFlags(FlagSpec{Name: "verbose", Short: "v", Bool: true})
+
+	var got *Request
+	h := func(req *Request) error {
+		got = req
+		return nil
+	}
+	r.Handle("comp <component> image <image>", "Show an image", h)
+	r.Handle("set <key>=<value>", "Set a value", h)
+	r.Handle("tag add <tags+>", "Add tags", h)
+
+	tests := []struct {
+		argv  []string
+		name  string
+		index int
+	}{
+		{[]string{"-v", "comp", "api", "image", "web"}, "component", 2},
+		{[]string{"-v", "comp", "api", "image", "web"}, "image", 4},
+		{[]string{"comp", "api", "image", "web"}, "component", 1},
+		{[]string{"comp", "api", "image", "web"}, "missing", -1},
+		{[]string{"set", "env=prod"}, "value", 1},
+		{[]string{"tag", "add", "a", "b"}, "tags", 2},
+	}
+	for _, tt := range tests {
+		if err := r.Run(context.Background(), tt.argv); err != nil {
+			t.Fatalf("Run(%v) returned error: %v", tt.argv, err)
+		}
+		if i := got.ParamIndex(tt.name); i != tt.index {
+			t.Errorf("Run(%v): ParamIndex(%q) = %d, want %d", tt.argv, tt.name, i, tt.index)
+		} else if i >= 0 && got.Args[i] != tt.argv[i] {
+			t.Errorf("index %d does not point into Args %v", i, got.Args)
+		}
+	}
+
+	if i := (&Request{}).ParamIndex("component"); i != -1 {
+		t.Fatalf("ParamIndex on a bare Request = %d, want -1", i)
+	}
+}
+
+func TestTypedContext_WithResolved(t *testing.T) {
+	r := New()
+
+	var steps []string
+	resolveApp := func(req *Request) (appCtx, error) {
+		steps = append(steps, "resolve")
+		if req.Params["component"] == "bad" {
+			return appCtx{}, errors.New("no such component")
+		}
+		return appCtx{Name: "cli-app"}, nil
+	}
+	before := func(next Handler) Handler {
+		return func(req *Request) error {
+			_, ok := FromContext[appCtx](req)
+			steps = append(steps, fmt.Sprintf("before resolved=%v", ok))
+			return next(req)
+		}
+	}
+	after := func(tag string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *Request) error {
+				app, _ := FromContext[appCtx](req)
+				steps = append(steps, tag+" "+app.Name)
+				return next(req)
+			}
+		}
+	}
+
+	r.Routes(func(b *Builder) {
+		app := WithContext(b, resolveApp).With(before).WithResolved(after("outer"))
+		app.Route("comp <component>", func(b *ContextBuilder[appCtx]) {
+			b.WithResolved(after("inner")).Handle("build", "Build", func(req *Request, app appCtx) error {
+				steps = append(steps, "handler "+app.Name)
+				return nil
+			})
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"comp", "api", "build"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	// FromContext in plain middleware resolves on demand.
+	want := "[resolve before resolved=true outer cli-app inner cli-app handler cli-app]"
+	if fmt.Sprint(steps) != want {
+		t.Fatalf("unexpected steps:\n%v\nwant:\n%v", steps, want)
+	}
+
+	steps = nil
+	if err := r.Run(context.Background(), []string{"comp", "bad", "build"}); err == nil {
+		t.Fatal("expected the resolver error")
+	}
+	if fmt.Sprint(steps) != "[resolve before resolved=false]" {
+		t.Fatalf("post-resolution middleware should not run on resolver errors: %v", steps)
+	}
+}
+
+func TestTypedContext_FromContextInMiddleware(t *testing.T) {
+	r := New()
+
+	resolves := 0
+	var seen []string
+	r.Before(func(req *Request) error {
+		app, ok := FromContext[appCtx](req)
+		seen = append(seen, fmt.Sprintf("before %s %v", app.Name, ok))
+		return nil
+	})
+	logApp := func(next Handler) Handler {
+		return func(req *Request) error {
+			app, ok := FromContext[appCtx](req)
+			seen = append(seen, fmt.Sprintf("middleware %s %v", app.Name, ok))
+			return next(req)
+		}
+	}
+
+	r.Routes(func(b *Builder) {
+		app := WithContext(b.With(logApp), func(*Request) (appCtx, error) {
+			resolves++
+			return appCtx{Name: "cli-app"}, nil
+		})
+		app.Handle("status", "Show status", func(_ *Request, app appCtx) error {
+			seen = append(seen, "handler "+app.Name)
+			return nil
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"status"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if want := "[before cli-app true middleware cli-app true handler cli-app]"; fmt.Sprint(seen) != want {
+		t.Fatalf("unexpected steps:\n%v\nwant:\n%v", seen, want)
+	}
+	if resolves != 1 {
+		t.Fatalf("resolver ran %d times, want 1", resolves)
+	}
+}
+
+func TestTypedContext_ResolversSeeMiddlewareValues(t *testing.T) {
+	type userKey struct{}
+	auth := func(next Handler) Handler {
+		return func(req *Request) error {
+			return next(req.WithContext(context.WithValue(req.Context(), userKey{}, "alice")))
+		}
+	}
+	user := func(req *Request) string {
+		u, _ := req.Context().Value(userKey{}).(string)
+		return u
+	}
+
+	type App struct{ User string }
+	type Comp struct{ User, Name string }
+
+	r := New()
+	var got []string
+	r.Routes(func(b *Builder) {
+		// Middleware added before the typed lift.
+		app := WithContext(b.With(auth), func(req *Request) (App, error) {
+			return App{User: user(req)}, nil
+		})
+		app.Handle("whoami", "Show user", func(_ *Request, a App) error {
+			got = append(got, "whoami "+a.User)
+			return nil
+		})
+
+		// Middleware added after the lift, seen by the parent layer too.
+		plain := WithContext(b, func(req *Request) (App, error) {
+			return App{User: user(req)}, nil
+		}).With(auth)
+		comp := WithChildContext(plain, func(a App, req *Request) (Comp, error) {
+			return Comp{User: a.User + "/" + user(req), Name: req.Params["component"]}, nil
+		})
+		comp.Handle("comp <component> show", "Show component", func(_ *Request, c Comp) error {
+			got = append(got, "comp "+c.Name+" "+c.User)
+			return nil
+		})
+	})
+
+	for _, argv := range [][]string{{"whoami"}, {"comp", "api", "show"}} {
+		if err := r.Run(context.Background(), argv); err != nil {
+			t.Fatalf("Run(%v) returned error: %v", argv, err)
+		}
+	}
+	if s := strings.Join(got, ", "); s != "whoami alice, comp api alice/alice" {
+		t.Fatalf("unexpected results: %s", s)
+	}
+}
+
+func TestTypedContext_HandleRaw(t *testing.T) {
+	r := New()
+
+	resolved := 0
+	resolveApp := func(req *Request) (appCtx, error) {
+		resolved++
+		return appCtx{Name: "cli-app"}, nil
+	}
+
+	var steps []string
+	mw := func(next Handler) Handler {
+		return func(req *Request) error {
+			steps = append(steps, "mw")
+			return next(req)
+		}
+	}
+
+	r.Routes(func(b *Builder) {
+		app := WithContext(b.With(mw), resolveApp)
+		app.Route("comp <component>", func(b *ContextBuilder[appCtx]) {
+			b.HandleRaw("legacy", "Old-style command", func(req *Request) error {
+				steps = append(steps, "raw "+req.Params["component"])
+				return nil
+			})
+			b.Handle("typed", "Typed command", func(req *Request, app appCtx) error {
+				steps = append(steps, "typed "+app.Name)
+				return nil
+			})
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"comp", "api", "legacy"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if resolved != 0 {
+		t.Fatalf("raw handlers should not resolve the context, resolved %d times", resolved)
+	}
+	if err := r.Run(context.Background(), []string{"comp", "api", "typed"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if fmt.Sprint(steps) != "[mw raw api mw typed cli-app]" {
+		t.Fatalf("unexpected steps: %v", steps)
+	}
+}
+
+func TestRouter_SetMaxArgs(t *testing.T) {
+	r := New()
+	r.Handle("echo <words...>", "Echo words", func(*Request) error { return nil })
+	r.SetMaxArgs(3)
+
+	if err := r.Run(context.Background(), []string{"echo", "a", "b"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	err := r.Run(context.Background(), []string{"echo", "a", "b", "c"})
+	if err == nil || err.Error() != "too many arguments: 4 (limit 3)" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r.SetMaxArgs(0)
+	if err := r.Run(context.Background(), strings.Fields(strings.Repeat("x ", 1000))); err == nil {
+		t.Fatal("expected no match for the unregistered command")
+	} else if strings.HasPrefix(err.Error(), "too many arguments") {
+		t.Fatalf("limit still applied after SetMaxArgs(0): %v", err)
+	}
+}
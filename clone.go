@@ -0,0 +1,65 @@
+package clir
+
+import "slices"
+
+// Clone returns a copy of r that can be changed without affecting r, e.g.
+// to build a variant command set from a common base. The clone is not
+// frozen, even if r is.
+//
+// Deep-copied: the route list and each route's metadata (descriptions,
+// param specs, flags, examples, aliases), the hook lists, the global flag
+// specs and the default command. Registering, hiding or removing routes
+// and adding hooks on either router leaves the other unchanged.
+//
+// Shared: handlers, middleware and hooks themselves, mounted routers, the
+// session, the output writers and the base context. The match cache, if
+// enabled, starts out empty with the same size.
+//
+// Example:
+//
+//	admin := r.Clone()
+//	admin.Handle("debug dump", "Dump internal state", dumpState)
+//	// r has no "debug dump"
+func (r *Router) Clone() *Router {
+	unlock := r.readLock()
+	defer unlock()
+
+	c := &Router{
+		preRun:         slices.Clone(r.preRun),
+		before:         slices.Clone(r.before),
+		after:          slices.Clone(r.after),
+		inlineMounts:   r.inlineMounts,
+		stdout:         r.stdout,
+		stderr:         r.stderr,
+		dryRun:         r.dryRun,
+		globalFlags:    slices.Clone(r.globalFlags),
+		color:          r.color,
+		session:        r.session,
+		name:           r.name,
+		stripProg:      r.stripProg,
+		tokenizer:      r.tokenizer,
+		groupHelp:      r.groupHelp,
+		helpFlag:       r.helpFlag,
+		maxArgs:        r.maxArgs,
+		helpWidth:      r.helpWidth,
+		helpOrder:      r.helpOrder,
+		baseCtx:        r.baseCtx,
+		responseFiles:  r.responseFiles,
+		noMatchMessage: r.noMatchMessage,
+		disallowExtra:  r.disallowExtra,
+		defaultArgv:    slices.Clone(r.defaultArgv),
+	}
+	if r.cache != nil {
+		c.cache = &matchCache{size: r.cache.size}
+	}
+	c.routes = make([]route, len(r.routes))
+	for i := range r.routes {
+		rt := r.routes[i].clone()
+		rt.ref = &CommandHandle{router: c}
+		if rt.builtin {
+			rt.handler = c.serveHelp
+		}
+		c.routes[i] = rt
+	}
+	return c
+}
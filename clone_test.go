@@ -0,0 +1,53 @@
+package clir
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRouter_Clone(t *testing.T) {
+	r, out := newHelpRouter()
+	r.EnableHelpCommand(true)
+	var hooks []string
+	r.Before(func(*Request) error {
+		hooks = append(hooks, "base")
+		return nil
+	})
+	h := r.Handle("status", "Show status", func(*Request) error { return nil })
+	r.Freeze()
+
+	c := r.Clone()
+	c.Handle("debug dump", "Dump internal state", func(*Request) error { return nil })
+	c.Before(func(*Request) error {
+		hooks = append(hooks, "clone")
+		return nil
+	})
+
+	if err := c.Run(context.Background(), []string{"debug", "dump"}); err != nil {
+		t.Fatalf("clone Run returned error: %v", err)
+	}
+	if err := r.Run(context.Background(), []string{"debug", "dump"}); err == nil {
+		t.Fatal("expected the original not to have the clone's command")
+	}
+	if got := strings.Join(hooks, ", "); got != "base, clone" {
+		t.Fatalf("unexpected hooks: %s", got)
+	}
+
+	// The clone's help command lists the clone's routes.
+	out.Reset()
+	if err := c.Run(context.Background(), []string{"help"}); err != nil {
+		t.Fatalf("clone Run returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "debug dump") {
+		t.Fatalf("clone help is missing its own command:\n%s", out.String())
+	}
+
+	// The original stays frozen; its handles still refer to it.
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected the original's handle to stay frozen")
+		}
+	}()
+	h.Hidden()
+}
@@ -0,0 +1,116 @@
+package clir
+
+import (
+	"io"
+	"os"
+	"slices"
+)
+
+// ColorMode controls whether the router's output uses ANSI colors.
+type ColorMode int
+
+const (
+	// ColorAuto colors output written to a terminal only (the default).
+	ColorAuto ColorMode = iota
+	// ColorAlways colors output regardless of the writer.
+	ColorAlways
+	// ColorNever disables colors.
+	ColorNever
+)
+
+// noColorFlag is the global flag that disables colors for an invocation.
+const noColorFlag = "no-color"
+
+const (
+	ansiBold  = "\x1b[1m"
+	ansiReset = "\x1b[0m"
+)
+
+// SetColor sets the color mode. Whatever the mode, a non-empty NO_COLOR
+// environment variable (https://no-color.org) or a --no-color flag on the
+// command line disables colors. The flag may come before the command,
+// with or without SetGlobalFlags, or after it. It applies to that
+// invocation only, so other lines of a REPL or Serve session keep their
+// colors.
+func (r *Router) SetColor(mode ColorMode) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetColor")
+	r.color = mode
+}
+
+// colorFor is the single place deciding whether output to w may be
+// colored, given the router's mode and whether the invocation had
+// --no-color. Every output path of the package goes through it.
+func colorFor(mode ColorMode, noColor bool, w io.Writer) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	return isTerminal(w)
+}
+
+// ColorEnabled reports whether the handler may color output written to
+// Request.Stdout, following the router's color decision. Use it in
+// handlers and logging middleware to stay consistent with help output.
+func (r *Request) ColorEnabled() bool {
+	return r.colorEnabled(r.Stdout)
+}
+
+// colorEnabled reports whether output of the request to w may be colored.
+// Callers must not hold the router's lock.
+func (r *Request) colorEnabled(w io.Writer) bool {
+	if r.router == nil {
+		return false
+	}
+	unlock := r.router.readLock()
+	mode := r.router.color
+	unlock()
+	return colorFor(mode, r.noColor, w)
+}
+
+// hasNoColor reports whether argv has a --no-color flag (before any "--").
+// The flag also stays in argv, so handlers can see it.
+func hasNoColor(argv []string) bool {
+	end := len(argv)
+	if i := slices.Index(argv, "--"); i >= 0 {
+		end = i
+	}
+	return slices.Contains(argv[:end], "--"+noColorFlag)
+}
+
+// trimNoColor drops leading --no-color flags from argv, so they are
+// accepted before the command without SetGlobalFlags.
+func trimNoColor(argv []string) []string {
+	for len(argv) > 0 && argv[0] == "--"+noColorFlag {
+		argv = argv[1:]
+	}
+	return argv
+}
+
+// isTerminal reports whether w is a character device such as a TTY.
+// Pipes, files and buffers are not.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// bold wraps s in bold ANSI codes when color is true.
+func bold(s string, color bool) string {
+	if !color {
+		return s
+	}
+	return ansiBold + s + ansiReset
+}
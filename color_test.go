@@ -0,0 +1,122 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRouter_Color(t *testing.T) {
+	newRouter := func() (*Router, *bytes.Buffer) {
+		r := New()
+		r.Handle("hello", "Say hello", func(*Request) error { return nil })
+		r.EnableHelpCommand(true)
+		var out bytes.Buffer
+		r.SetOutput(&out, &out)
+		return r, &out
+	}
+	colored := func(s string) bool { return strings.Contains(s, ansiBold) }
+
+	t.Run("auto is off for non-terminals", func(t *testing.T) {
+		r, out := newRouter()
+		r.PrintHelp(out)
+		if colored(out.String()) {
+			t.Fatalf("unexpected color: %q", out.String())
+		}
+	})
+
+	t.Run("always", func(t *testing.T) {
+		r, out := newRouter()
+		r.SetColor(ColorAlways)
+		r.PrintHelp(out)
+		if !colored(out.String()) {
+			t.Fatalf("expected color: %q", out.String())
+		}
+		if !strings.Contains(out.String(), ansiBold+"hello"+ansiReset+"  Say hello") {
+			t.Fatalf("colored help misaligned: %q", out.String())
+		}
+	})
+
+	t.Run("NO_COLOR wins", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		r, out := newRouter()
+		r.SetColor(ColorAlways)
+		r.PrintHelp(out)
+		if colored(out.String()) {
+			t.Fatalf("unexpected color: %q", out.String())
+		}
+	})
+
+	t.Run("--no-color wins", func(t *testing.T) {
+		r, out := newRouter()
+		r.SetColor(ColorAlways)
+
+		var handlerColor bool
+		r.Handle("check", "Check", func(req *Request) error {
+			handlerColor = req.ColorEnabled()
+			return nil
+		})
+
+		if err := r.Run(context.Background(), []string{"help", "hello", "--no-color"}); err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		if err := r.Run(context.Background(), []string{"check", "--no-color"}); err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		if colored(out.String()) || handlerColor {
+			t.Fatalf("unexpected color: handler=%v out=%q", handlerColor, out.String())
+		}
+	})
+
+	t.Run("--no-color applies to one invocation", func(t *testing.T) {
+		r, out := newRouter()
+		r.SetColor(ColorAlways)
+
+		var handlerColor bool
+		r.Handle("check", "Check", func(req *Request) error {
+			handlerColor = req.ColorEnabled()
+			return nil
+		})
+
+		for _, argv := range [][]string{{"help", "hello", "--no-color"}, {"check", "--no-color"}} {
+			if err := r.Run(context.Background(), argv); err != nil {
+				t.Fatalf("Run(%q) returned error: %v", argv, err)
+			}
+		}
+		out.Reset()
+		for _, argv := range [][]string{{"help", "hello"}, {"check"}} {
+			if err := r.Run(context.Background(), argv); err != nil {
+				t.Fatalf("Run(%q) returned error: %v", argv, err)
+			}
+		}
+		if !colored(out.String()) || !handlerColor {
+			t.Fatalf("color not restored: handler=%v out=%q", handlerColor, out.String())
+		}
+	})
+
+	t.Run("--no-color before the command", func(t *testing.T) {
+		r, _ := newRouter()
+		r.SetColor(ColorAlways)
+		var handlerColor bool
+		r.Handle("comp <component> image build", "Build images", func(req *Request) error {
+			handlerColor = req.ColorEnabled()
+			return nil
+		})
+		argv := []string{"--no-color", "comp", "x", "image", "build"}
+		if err := r.Run(context.Background(), argv); err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		if handlerColor {
+			t.Fatal("leading --no-color did not disable color")
+		}
+	})
+
+	t.Run("--no-color accepted as a global flag", func(t *testing.T) {
+		r, _ := newRouter()
+		r.SetGlobalFlags()
+		if err := r.Run(context.Background(), []string{"--no-color", "hello"}); err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package clir
+
+import (
+	"sort"
+	"time"
+)
+
+// Command describes a registered route for tooling such as completion,
+// documentation or diagnostics.
+type Command struct {
+	// Pattern is the route's pattern without sort hints,
+	// e.g. "comp <component> image build". Literals that would otherwise
+	// parse as something else keep their backslash, e.g. `ipv \4`, so
+	// Pattern can be passed back to Invoke.
+	Pattern string
+
+	// Desc is the one-line description given at registration.
+	Desc string
+
+	// Long is the detailed help text set with Builder.Long, or "".
+	Long string
+
+	// Params are the route's params in pattern order.
+	Params []CommandParam
+
+	// Flags are the flags declared with Builder.Flags.
+	Flags []FlagSpec
+
+	// Timeout is the default deadline set with Builder.Timeout, or 0.
+	Timeout time.Duration
+
+	// Hidden reports whether the command is left out of help listings.
+	Hidden bool
+
+	// Examples are the usage examples added with CommandHandle.Example.
+	Examples []string
+
+	// Deprecated is the deprecation message, or "" if not deprecated.
+	Deprecated string
+
+	// Aliases are the patterns registered as aliases with HandleWith.
+	Aliases []string
+
+	// Group is the help heading set with CommandHandle.Group, or "".
+	Group string
+}
+
+// command returns the Command describing rt.
+func (rt *route) command() Command {
+	return Command{
+		Pattern:    rt.String(),
+		Desc:       rt.desc,
+		Long:       rt.long,
+		Params:     rt.commandParams(),
+		Flags:      rt.flags,
+		Timeout:    rt.timeout,
+		Hidden:     rt.hidden,
+		Examples:   rt.examples,
+		Deprecated: rt.deprecated,
+		Aliases:    rt.aliases,
+		Group:      rt.group,
+	}
+}
+
+// Commands returns every registered route in registration order,
+// including mount points and the built-in help command.
+func (r *Router) Commands() []Command {
+	unlock := r.readLock()
+	defer unlock()
+
+	out := make([]Command, 0, len(r.routes))
+	for i := range r.routes {
+		out = append(out, r.routes[i].command())
+	}
+	return out
+}
+
+// Matches returns every route matching argv, best first: the first entry
+// is the route Run would execute. Routes of equal rank keep their
+// registration order. It returns nil when nothing matches.
+func (r *Router) Matches(argv []string) []Command {
+	unlock := r.readLock()
+	defer unlock()
+
+	type ranked struct {
+		rt   *route
+		rank uint64
+	}
+	var matches []ranked
+	for i := range r.routes {
+		if rank := r.routes[i].matchArgv(argv); rank != 0 {
+			matches = append(matches, ranked{&r.routes[i], rank})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].rank > matches[j].rank
+	})
+
+	var out []Command
+	for _, m := range matches {
+		out = append(out, m.rt.command())
+	}
+	return out
+}
+
+// Subcommands returns the routes that extend the matched route, in
+// registration order: routes with more segments whose leading segments
+// accept the tokens the matched route consumed. Hidden routes are left
+// out. A handler for a group
+// such as "comp <component>" can use it to list what may follow.
+// It returns nil for Requests not created by Run.
+//
+// Example:
+//
+//	// routes: "comp <component>", "comp <component> image build"
+//	// argv: comp api
+//	req.Subcommands() // [{Pattern: "comp <component> image build", ...}]
+func (r *Request) Subcommands() []Command {
+	if r.route == nil || r.router == nil {
+		return nil
+	}
+	n := len(r.route.segments)
+	if r.argOffset+n > len(r.Args) {
+		return nil
+	}
+	tokens := r.Args[r.argOffset : r.argOffset+n]
+
+	unlock := r.router.readLock()
+	defer unlock()
+
+	var out []Command
+	for i := range r.router.routes {
+		rt := &r.router.routes[i]
+		if rt == r.route || rt.builtin || rt.hidden || len(rt.segments) <= n {
+			continue
+		}
+		if hasSegmentPrefix(rt.segments, tokens) {
+			out = append(out, rt.command())
+		}
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+package clir
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRouter_Matches(t *testing.T) {
+	r := New()
+	noop := func(*Request) error { return nil }
+	r.Handle("users <id>", "Show user", noop)
+	r.Handle("users me", "Show me", noop)
+	r.Handle("users <name>", "Show by name", noop)
+	r.Handle("users", "List users", noop)
+	r.Handle("groups", "List groups", noop)
+
+	argv := []string{"users", "me"}
+	got := r.Matches(argv)
+
+	var pats []string
+	for _, c := range got {
+		pats = append(pats, c.Desc)
+	}
+	want := "[Show me Show user Show by name List users]"
+	if fmt.Sprint(pats) != want {
+		t.Fatalf("Matches = %v, want %v", pats, want)
+	}
+
+	rt, _, _ := r.bestMatch(context.Background(), argv)
+	if got[0].Pattern != rt.String() {
+		t.Fatalf("first match %q differs from bestMatch %q", got[0].Pattern, rt.String())
+	}
+
+	if m := r.Matches([]string{"nope"}); m != nil {
+		t.Fatalf("expected no matches, got %v", m)
+	}
+}
+
+func TestRouter_Commands(t *testing.T) {
+	r := New()
+	noop := func(*Request) error { return nil }
+	r.Routes(func(b *Builder) {
+		b.Route("comp <component>", func(b *Builder) {
+			b.Long("Build images.\n\nTags default to the commit.").Handle("image build", "Build images", noop)
+			b.Handle("image push", "Push images", noop)
+		})
+	})
+
+	got := r.Commands()
+	component := []CommandParam{{Name: "component"}}
+	want := []Command{
+		{Pattern: "comp <component> image build", Desc: "Build images", Long: "Build images.\n\nTags default to the commit.", Params: component},
+		{Pattern: "comp <component> image push", Desc: "Push images", Params: component},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Commands() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRequest_Subcommands(t *testing.T) {
+	r := New()
+	r.EnableHelpCommand(true)
+
+	var subs []Command
+	group := func(req *Request) error {
+		subs = req.Subcommands()
+		return nil
+	}
+	noop := func(*Request) error { return nil }
+	r.Handle("comp <component>", "Component commands", group)
+	r.Handle("comp <component> image build", "Build images", noop)
+	r.Handle("comp <component> image push", "Push images", noop)
+	r.Handle("comp list", "List components", noop)
+	r.Handle("users <id> delete", "Delete a user", noop)
+
+	if err := r.Run(context.Background(), []string{"comp", "api"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	var pats []string
+	for _, c := range subs {
+		pats = append(pats, c.Pattern)
+	}
+	if fmt.Sprint(pats) != "[comp <component> image build comp <component> image push]" {
+		t.Fatalf("Subcommands() = %v", pats)
+	}
+
+	if got := (&Request{}).Subcommands(); got != nil {
+		t.Fatalf("Subcommands() on a bare Request = %v", got)
+	}
+}
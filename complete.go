@@ -0,0 +1,63 @@
+package clir
+
+import (
+	"slices"
+	"strings"
+)
+
+// Complete returns the words that may follow argv, for shell completion:
+// argv holds the complete tokens before the cursor (without the program
+// name) and partial the word being typed, possibly "". It matches argv
+// against the leading segments of every route and offers the literal at
+// the next position when it starts with partial. Mounted routers complete
+// their own commands once argv reaches past the mount point.
+//
+// Params and globs offer nothing, as any token would do. Hidden routes are
+// left out. Leading global flags are skipped, and nothing is offered after
+// a "--". The result is sorted and free of duplicates.
+//
+// Example:
+//
+//	// routes: "comp <component> image build", "comp <component> image push"
+//	r.Complete([]string{"comp", "cv-server"}, "im") // ["image"]
+//	r.Complete([]string{"comp", "cv-server", "image"}, "") // ["build", "push"]
+func (r *Router) Complete(argv []string, partial string) []string {
+	unlock := r.readLock()
+	defer unlock()
+
+	if r.globalFlags != nil {
+		specs := append(r.globalFlags[:len(r.globalFlags):len(r.globalFlags)],
+			FlagSpec{Name: noColorFlag, Bool: true})
+		var err error
+		if _, argv, err = parseFlags(argv, specs, true); err != nil {
+			return nil
+		}
+	} else {
+		argv = trimNoColor(argv)
+	}
+	if slices.Contains(argv, "--") {
+		return nil
+	}
+
+	var out []string
+	n := len(argv)
+	for i := range r.routes {
+		rt := &r.routes[i]
+		if rt.hidden {
+			continue
+		}
+		if rt.mount != nil && n >= len(rt.segments) && hasSegmentPrefix(rt.segments, argv[:len(rt.segments)]) {
+			out = append(out, rt.mount.Complete(argv[len(rt.segments):], partial)...)
+			continue
+		}
+		if n >= len(rt.segments) || !hasSegmentPrefix(rt.segments, argv) {
+			continue
+		}
+		if lit := rt.segments[n].lit; lit != "" && strings.HasPrefix(lit, partial) {
+			out = append(out, lit)
+		}
+	}
+
+	slices.Sort(out)
+	return slices.Compact(out)
+}
@@ -0,0 +1,51 @@
+package clir
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRouter_Complete(t *testing.T) {
+	noop := func(*Request) error { return nil }
+	plugins := New()
+	plugins.Handle("list", "List plugins", noop)
+	plugins.Handle("install <name>", "Install a plugin", noop)
+
+	r := New()
+	r.SetGlobalFlags(FlagSpec{Name: "verbose", Short: "v", Bool: true})
+	r.Handle("comp <component> image build", "Build images", noop)
+	r.Handle("comp <component> image push", "Push images", noop)
+	r.Handle("comp <component> info", "Show info", noop)
+	r.Handle("comp list", "List components", noop)
+	r.Handle("1 config set <key>", "Set config", noop)
+	r.Handle("log v*", "Show a version log", noop)
+	r.Handle("debug dump", "Dump state", noop).Hidden()
+	r.Mount("plugin", plugins)
+
+	tests := []struct {
+		argv    []string
+		partial string
+		want    string
+	}{
+		{nil, "", "[comp config log plugin]"},
+		{nil, "co", "[comp config]"},
+		{[]string{"comp"}, "", "[list]"},
+		{[]string{"comp", "cv-server"}, "im", "[image]"},
+		{[]string{"comp", "cv-server"}, "", "[image info]"},
+		{[]string{"comp", "cv-server", "image"}, "", "[build push]"},
+		{[]string{"comp", "cv-server", "image"}, "b", "[build]"},
+		{[]string{"-v", "comp", "cv-server"}, "i", "[image info]"},
+		{[]string{"comp", "cv-server", "image", "build"}, "", "[]"},
+		{[]string{"comp", "--", "x"}, "", "[]"},
+		{[]string{"log"}, "", "[]"},
+		{[]string{"debug"}, "", "[]"},
+		{[]string{"plugin"}, "", "[install list]"},
+		{[]string{"plugin"}, "l", "[list]"},
+		{[]string{"--unknown"}, "", "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(r.Complete(tt.argv, tt.partial)); got != tt.want {
+			t.Errorf("Complete(%q, %q) = %s, want %s", tt.argv, tt.partial, got, tt.want)
+		}
+	}
+}
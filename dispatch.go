@@ -0,0 +1,44 @@
+package clir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxDispatchDepth is how deeply Request.Dispatch calls may nest.
+const maxDispatchDepth = 16
+
+// Dispatch runs argv through the router that dispatched r, as a new
+// invocation matched from scratch, so a command can be defined in terms
+// of another: a handler for "deploy <env>" can run "deploy --env <env>".
+// For a command of a mounted router, argv is relative to the mount point.
+//
+// The new Request gets ctx (r.Context() if nil), with the router's base
+// context and session applied as in Run. It writes to r's Stdout and
+// Stderr unless the router has its own SetOutput, and keeps r's
+// StartedAt. PreRun hooks and response files apply; the program name is
+// not stripped. Dispatches nest at most 16 deep, so a command that
+// dispatches to itself fails instead of recursing forever.
+//
+// Example:
+//
+//	r.Handle("deploy prod", "Deploy to production", func(req *clir.Request) error {
+//	    return req.Dispatch(req.Context(), []string{"deploy", "--env", "prod"})
+//	})
+func (r *Request) Dispatch(ctx context.Context, argv []string) error {
+	if r.router == nil {
+		return errors.New("dispatch: request was not created by a router")
+	}
+	if r.depth >= maxDispatchDepth {
+		return fmt.Errorf("dispatch of `%s`: nested more than %d deep", strings.Join(argv, " "), maxDispatchDepth)
+	}
+	if ctx == nil {
+		ctx = r.Context()
+	}
+	parent := *r
+	parent.route = nil
+	parent.depth++
+	return r.router.run(ctx, argv, &parent)
+}
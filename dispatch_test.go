@@ -0,0 +1,65 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRequest_Dispatch(t *testing.T) {
+	r := New()
+	var out bytes.Buffer
+	r.SetOutput(&out, &out)
+	type key struct{}
+
+	r.Handle("deploy", "Deploy", func(req *Request) error {
+		env, _ := req.Flag("env")
+		req.Printf("deploy env=%s user=%v\n", env, req.Context().Value(key{}))
+		return nil
+	})
+	r.Handle("deploy prod", "Deploy to production", func(req *Request) error {
+		ctx := context.WithValue(req.Context(), key{}, "alice")
+		return req.Dispatch(ctx, []string{"deploy", "--env", "prod"})
+	})
+	r.Handle("loop", "Dispatch to itself", func(req *Request) error {
+		return req.Dispatch(nil, []string{"loop"})
+	})
+
+	if err := r.Run(context.Background(), []string{"deploy", "prod"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := out.String(); got != "deploy env=prod user=alice\n" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+
+	err := r.Run(context.Background(), []string{"loop"})
+	if err == nil || !strings.Contains(err.Error(), "nested more than 16 deep") {
+		t.Fatalf("expected the depth limit, got: %v", err)
+	}
+
+	if err := (&Request{}).Dispatch(context.Background(), []string{"deploy"}); err == nil {
+		t.Fatal("expected Dispatch without a router to fail")
+	}
+}
+
+func TestRequest_Dispatch_Mounted(t *testing.T) {
+	child := New()
+	var got []string
+	child.Handle("install <name>", "Install a plugin", func(req *Request) error {
+		got = append(got, req.Params["name"])
+		return nil
+	})
+	child.Handle("setup", "Install the defaults", func(req *Request) error {
+		return req.Dispatch(req.Context(), []string{"install", "core"})
+	})
+	r := New()
+	r.Mount("plugin", child)
+
+	if err := r.Run(context.Background(), []string{"plugin", "setup"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if strings.Join(got, ",") != "core" {
+		t.Fatalf("unexpected installs: %v", got)
+	}
+}
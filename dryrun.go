@@ -0,0 +1,64 @@
+package clir
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// dryRunFlag is the flag that triggers a dry run when enabled.
+const dryRunFlag = "dry-run"
+
+// EnableDryRun turns the built-in --dry-run flag on or off. When enabled
+// and --dry-run appears in Extra, Run prints the matched pattern, the
+// resolved params and the flags found in Extra to Request.Stdout instead
+// of executing the handler. Hooks and middleware don't run either.
+func (r *Router) EnableDryRun(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("EnableDryRun")
+	r.dryRun = enabled
+	for i := range r.routes {
+		r.applyExtra(&r.routes[i])
+	}
+	r.trie.Store(nil)
+}
+
+// printPlan writes the dry-run plan for rt and req to w.
+func printPlan(w io.Writer, rt *route, req *Request, color bool) {
+	fmt.Fprintf(w, "%s would execute %q\n", bold("Dry run:", color), rt.String())
+
+	if len(req.Params) > 0 {
+		fmt.Fprintln(w, "  params:")
+		keys := make([]string, 0, len(req.Params))
+		for k := range req.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "    %s = %s\n", k, req.Params[k])
+		}
+	}
+
+	var flags []string
+	for _, tok := range req.Extra {
+		if tok == "--" {
+			break
+		}
+		name, _, _, ok := flagName(tok)
+		if !ok || name == dryRunFlag {
+			continue
+		}
+		if v, ok := req.Flag(name); ok {
+			flags = append(flags, fmt.Sprintf("--%s = %s", name, v))
+		} else {
+			flags = append(flags, "--"+name)
+		}
+	}
+	if len(flags) > 0 {
+		fmt.Fprintln(w, "  flags:")
+		for _, f := range flags {
+			fmt.Fprintf(w, "    %s\n", f)
+		}
+	}
+}
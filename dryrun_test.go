@@ -0,0 +1,57 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestRouter_EnableDryRun(t *testing.T) {
+	r := New()
+
+	var called bool
+	r.Handle("comp <component> image build", "Build images", func(req *Request) error {
+		called = true
+		return nil
+	})
+
+	var out bytes.Buffer
+	r.SetOutput(&out, nil)
+	r.EnableDryRun(true)
+
+	argv := []string{"comp", "api", "image", "build", "--tag", "latest", "--push", "--dry-run"}
+	if err := r.Run(context.Background(), argv); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if called {
+		t.Fatal("handler should not run in dry-run mode")
+	}
+
+	want := `Dry run: would execute "comp <component> image build"
+  params:
+    component = api
+  flags:
+    --tag = latest
+    --push
+`
+	if out.String() != want {
+		t.Fatalf("unexpected plan:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRouter_DryRunFlag_IgnoredWhenDisabled(t *testing.T) {
+	r := New()
+
+	var called bool
+	r.Handle("deploy", "Deploy", func(req *Request) error {
+		called = true
+		return nil
+	})
+
+	if err := r.Run(context.Background(), []string{"deploy", "--dry-run"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if !called {
+		t.Fatal("handler should run when dry-run is disabled")
+	}
+}
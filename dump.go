@@ -0,0 +1,115 @@
+package clir
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Len returns the number of registered routes, counting hidden routes,
+// aliases, mount points and the built-in help command.
+func (r *Router) Len() int {
+	unlock := r.readLock()
+	defer unlock()
+	return len(r.routes)
+}
+
+// Dump writes every registered route in registration order, as a
+// diagnostic for generated or large route tables: the pattern as
+// registered, then each segment with its kind and sort hint, then the
+// route's attributes (hidden, alias, mount, exact, ...). Unlike Explain
+// it involves no argv. It is safe to call at any time.
+//
+// Example output:
+//
+//	Routes (2):
+//	  1. 2 comp <component> image build
+//	     literal "comp" (sort 2), param <component>, literal "image", literal "build"
+//	  2. exec <cmd...>
+//	     literal "exec", rest <cmd...>
+//	     hidden
+func (r *Router) Dump(w io.Writer) {
+	unlock := r.readLock()
+	defer unlock()
+
+	fmt.Fprintf(w, "Routes (%d):\n", len(r.routes))
+	for i := range r.routes {
+		rt := &r.routes[i]
+		fmt.Fprintf(w, "  %d. %s\n", i+1, strings.Join(rt.parts, " "))
+
+		kinds := make([]string, len(rt.segments))
+		for j, s := range rt.segments {
+			kinds[j] = s.kind()
+			if s.sort != 0 {
+				kinds[j] += fmt.Sprintf(" (sort %d)", s.sort)
+			}
+		}
+		if len(kinds) > 0 {
+			fmt.Fprintf(w, "     %s\n", strings.Join(kinds, ", "))
+		}
+		if attrs := rt.attrs(); len(attrs) > 0 {
+			fmt.Fprintf(w, "     %s\n", strings.Join(attrs, ", "))
+		}
+	}
+}
+
+// kind describes s for Dump, e.g. `literal "comp"` or "param <id>".
+func (s segment) kind() string {
+	switch {
+	case s.lit != "":
+		return "literal " + strconv.Quote(s.lit)
+	case s.key != "":
+		return fmt.Sprintf("key=value <%s>=<%s>", s.key, s.val)
+	case s.glob != "":
+		return "glob " + strconv.Quote(s.glob)
+	case s.rest:
+		return "rest <" + s.param + "...>"
+	case s.list:
+		return "list <" + s.param + "+>"
+	}
+	k := "param <" + s.param + ">"
+	if s.req {
+		k += " required"
+	}
+	if s.noFlags {
+		k += " no-flags"
+	}
+	return k
+}
+
+// attrs lists the attributes of rt that Dump shows.
+func (rt *route) attrs() []string {
+	var out []string
+	if rt.builtin {
+		out = append(out, "builtin")
+	}
+	if rt.mount != nil {
+		out = append(out, "mount")
+	}
+	if rt.hidden {
+		out = append(out, "hidden")
+	}
+	if rt.aliasOf != "" {
+		out = append(out, fmt.Sprintf("alias of %q", rt.aliasOf))
+	}
+	if rt.exact {
+		out = append(out, "exact")
+	}
+	switch rt.strict {
+	case strictAll:
+		out = append(out, "strict")
+	case strictArgs:
+		out = append(out, "strict args")
+	}
+	if rt.timeout > 0 {
+		out = append(out, "timeout "+rt.timeout.String())
+	}
+	if rt.deprecated != "" {
+		out = append(out, "deprecated")
+	}
+	if rt.group != "" {
+		out = append(out, fmt.Sprintf("group %q", rt.group))
+	}
+	return out
+}
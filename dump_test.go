@@ -0,0 +1,50 @@
+package clir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRouter_Dump(t *testing.T) {
+	r := New()
+	noop := func(*Request) error { return nil }
+	r.EnableHelpCommand(true)
+	r.Routes(func(b *Builder) {
+		b.Param("component", Required()).Handle("2 comp <component> image build", "Build images", noop)
+		b.Exact().Handle("set <k>=<v> v?", "Set", noop)
+	})
+	r.HandleWith("remote remove <name>", CommandOptions{Desc: "Remove a remote", Aliases: []string{"remote rm <name>"}}, noop)
+	r.Mount("plugin", New())
+	r.Handle("exec <cmd...>", "Run a program", noop).Hidden()
+
+	if n := r.Len(); n != 7 {
+		t.Fatalf("Len() = %d, want 7", n)
+	}
+
+	var out bytes.Buffer
+	r.Dump(&out)
+	want := `Routes (7):
+  1. help
+     literal "help"
+     builtin
+  2. 2 comp <component> image build
+     literal "comp" (sort 2), param <component> required, literal "image", literal "build"
+  3. set <k>=<v> v?
+     literal "set", key=value <k>=<v>, glob "v?"
+     exact
+  4. remote remove <name>
+     literal "remote", literal "remove", param <name>
+  5. remote rm <name>
+     literal "remote", literal "rm", param <name>
+     hidden, alias of "remote remove <name>"
+  6. plugin
+     literal "plugin"
+     mount
+  7. exec <cmd...>
+     literal "exec", rest <cmd...>
+     hidden
+`
+	if out.String() != want {
+		t.Fatalf("unexpected dump:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
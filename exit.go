@@ -0,0 +1,136 @@
+package clir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Exit codes returned by HandleError.
+const (
+	ExitOK    = 0 // no error
+	ExitError = 1 // the command failed
+	ExitUsage = 2 // the command line was wrong, e.g. no command matched
+)
+
+// ErrorPrinter is implemented by errors that render themselves for
+// HandleError instead of the default "Error: <msg>" line.
+type ErrorPrinter interface {
+	PrintError(w io.Writer)
+}
+
+// ExitCoder is implemented by errors that choose HandleError's exit code.
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// HandleError writes err to the router's stderr (see SetOutput) the way a
+// CLI's main usually does, and returns the process exit code:
+//
+//   - nil prints nothing and returns ExitOK.
+//   - an error implementing ErrorPrinter prints itself; one implementing
+//     ExitCoder chooses the code. Both are found with errors.As.
+//   - a *NoMatchError prints "Error: <msg>" followed by the commands
+//     starting with the matched prefix, or the full command list when
+//     nothing matched, and returns ExitUsage.
+//   - ErrShowHelp prints the command list and returns ExitUsage. For a
+//     matched command, Run has already printed the command's help, so
+//     nothing more is printed; the full list is only shown when the
+//     error comes from elsewhere, e.g. a PreRun hook.
+//   - any other error prints "Error: <msg>" and returns ExitError. An
+//     error joining several (see errors.Join), such as the failures of
+//     typed params or required flags, prints them as a bullet list
+//     under "Error:".
+//
+// Example:
+//
+//	func main() {
+//	    os.Exit(r.HandleError(r.RunArgs(context.Background())))
+//	}
+func (r *Router) HandleError(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	unlock := r.readLock()
+	w := r.stderr
+	unlock()
+	if w == nil {
+		w = os.Stderr
+	}
+
+	code := ExitError
+	var printer ErrorPrinter
+	var noMatch *NoMatchError
+	switch {
+	case errors.As(err, &printer):
+		printer.PrintError(w)
+	case errors.As(err, &noMatch):
+		code = ExitUsage
+		fmt.Fprintln(w, "Error:", err)
+		fmt.Fprintln(w)
+		r.printSuggestions(w, noMatch.MatchedPrefix, noMatch.noColor)
+	case errors.Is(err, ErrShowHelp):
+		code = ExitUsage
+		if err != ErrShowHelp {
+			fmt.Fprintln(w, "Error:", err)
+			fmt.Fprintln(w)
+		}
+		r.PrintHelp(w)
+	default:
+		printError(w, err)
+	}
+
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		code = coder.ExitCode()
+	}
+	return code
+}
+
+// RunMain runs the program's own arguments like RunArgs and reports the
+// outcome with HandleError, returning the exit code.
+//
+// Example:
+//
+//	func main() {
+//	    os.Exit(r.RunMain(context.Background()))
+//	}
+func (r *Router) RunMain(ctx context.Context) int {
+	return r.HandleError(r.RunArgs(ctx))
+}
+
+// printError prints err as "Error: <msg>", or, if it joins several
+// errors, as a bullet list with one line per error.
+func printError(w io.Writer, err error) {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) < 2 {
+		fmt.Fprintln(w, "Error:", err)
+		return
+	}
+	fmt.Fprintln(w, "Error:")
+	for _, e := range joined.Unwrap() {
+		fmt.Fprintf(w, "  - %s\n", strings.ReplaceAll(e.Error(), "\n", "\n    "))
+	}
+}
+
+// printSuggestions lists the commands starting with tokens, or all
+// commands if there are none, without color if noColor is set.
+func (r *Router) printSuggestions(w io.Writer, tokens []string, noColor bool) {
+	unlock := r.readLock()
+	var entries []helpEntry
+	if len(tokens) > 0 {
+		entries = r.prefixEntries(tokens)
+	}
+	style := r.listStyle(w, noColor)
+	unlock()
+
+	if len(entries) == 0 {
+		r.printHelp(w, noColor)
+		return
+	}
+	printMatching(w, tokens, entries, style)
+}
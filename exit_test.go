@@ -0,0 +1,89 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+type quietError struct{}
+
+func (quietError) Error() string          { return "quiet" }
+func (quietError) PrintError(w io.Writer) { fmt.Fprintln(w, "custom output") }
+func (quietError) ExitCode() int          { return 3 }
+
+func TestRouter_HandleError(t *testing.T) {
+	r := New()
+	r.SetName("mytool")
+	noop := func(*Request) error { return nil }
+	r.Handle("comp <component> image build", "Build images", noop)
+	r.Handle("comp <component> image push", "Push images", noop)
+	r.Handle("version", "Show version", noop)
+	var out bytes.Buffer
+	r.SetOutput(&out, &out)
+
+	noMatch := r.Run(context.Background(), []string{"comp", "api", "image", "bld"})
+	unknown := r.Run(context.Background(), []string{"bogus"})
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+		want string
+	}{
+		{"nil", nil, ExitOK, ""},
+		{"plain", errors.New("boom"), ExitError, "Error: boom\n"},
+		{"joined", errors.Join(errors.New("bad <count>"), errors.New("missing --tag")), ExitError,
+			"Error:\n  - bad <count>\n  - missing --tag\n"},
+		{"joined one", errors.Join(errors.New("boom")), ExitError, "Error: boom\n"},
+		{"custom", fmt.Errorf("wrapped: %w", quietError{}), 3, "custom output\n"},
+		{"no match", noMatch, ExitUsage, "Error: " + noMatch.Error() + "\n\n" +
+			"Commands matching `comp api image`:\n" +
+			"  comp <component> image build  Build images\n" +
+			"  comp <component> image push   Push images\n"},
+		{"unknown", unknown, ExitUsage, "Error: " + unknown.Error() + "\n\n" +
+			"Available commands:\n" +
+			"  comp <component> image build  Build images\n" +
+			"  comp <component> image push   Push images\n" +
+			"  version                       Show version\n"},
+		{"show help", ErrShowHelp, ExitUsage, "Available commands:\n" +
+			"  comp <component> image build  Build images\n" +
+			"  comp <component> image push   Push images\n" +
+			"  version                       Show version\n"},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		if code := r.HandleError(tt.err); code != tt.code {
+			t.Errorf("%s: code = %d, want %d", tt.name, code, tt.code)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: output:\n%q\nwant:\n%q", tt.name, out.String(), tt.want)
+		}
+	}
+}
+
+func TestRouter_RunMain_ShowHelp(t *testing.T) {
+	r := New()
+	r.SetName("mytool")
+	r.Handle("deploy <env>", "Deploy to an environment", func(*Request) error {
+		return fmt.Errorf("bad: %w", ErrShowHelp)
+	})
+	var out bytes.Buffer
+	r.SetOutput(&out, &out)
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = []string{"mytool", "deploy", "prod"}
+
+	if code := r.RunMain(context.Background()); code != ExitUsage {
+		t.Fatalf("RunMain = %d, want %d", code, ExitUsage)
+	}
+	want := "Error: bad: show help\nUsage: mytool deploy <env>\n\nDeploy to an environment\n\nArguments:\n  <env>\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
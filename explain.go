@@ -0,0 +1,102 @@
+package clir
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Explain writes a human-readable account of how argv is matched: for
+// each route, in registration order, either its rank or the reason it
+// failed, followed by the route Run would select. It is a diagnostic aid
+// for the ranking and sort-hint system and doesn't change Run.
+//
+// Ranks are shown as one letter per segment, L for a literal match, G for
+// a glob match and P for a param match, and compared left to right with
+// L > G > P, a longer match beating its prefix.
+//
+// Example output:
+//
+//	Explain `users me`:
+//	  1. users <id>   rank LP
+//	  2. users me     rank LL
+//	  3. groups       no match: segment 1 is literal "groups", got "users"
+//	Selected: users me
+func (r *Router) Explain(argv []string, w io.Writer) {
+	unlock := r.readLock()
+	defer unlock()
+
+	fmt.Fprintf(w, "Explain `%s`:\n", strings.Join(argv, " "))
+
+	maxLen := 0
+	for i := range r.routes {
+		if l := len(r.routes[i].String()); l > maxLen {
+			maxLen = l
+		}
+	}
+
+	for i := range r.routes {
+		rt := &r.routes[i]
+		fmt.Fprintf(w, "  %d. %-*s  %s\n", i+1, maxLen, rt.String(), rt.explain(argv))
+	}
+
+	if rt, ok := r.lookup(argv); ok {
+		fmt.Fprintf(w, "Selected: %s\n", rt.String())
+	} else {
+		fmt.Fprintln(w, "Selected: none")
+	}
+}
+
+// explain mirrors matchArgv, describing the outcome instead of ranking.
+func (rt *route) explain(argv []string) string {
+	segs := rt.segments
+	switch {
+	case len(segs) == 0:
+		return "no match: empty pattern"
+	case len(segs) > 32:
+		return fmt.Sprintf("no match: %d segments exceeds the limit of 32", len(segs))
+	case len(argv) < len(segs):
+		return fmt.Sprintf("no match: needs %d args, got %d", len(segs), len(argv))
+	}
+
+	var codes strings.Builder
+	for i, s := range segs {
+		switch {
+		case s.lit != "":
+			if argv[i] != s.lit {
+				return fmt.Sprintf("no match: segment %d is literal %q, got %q", i+1, s.lit, argv[i])
+			}
+			codes.WriteByte('L')
+		case s.key != "":
+			if !s.matches(argv[i]) {
+				return fmt.Sprintf("no match: segment %d is <%s>=<%s>, got %q", i+1, s.key, s.val, argv[i])
+			}
+			codes.WriteByte('G')
+		case s.glob != "":
+			if !globMatch(s.glob, argv[i]) {
+				return fmt.Sprintf("no match: segment %d is glob %q, got %q", i+1, s.glob, argv[i])
+			}
+			codes.WriteByte('G')
+		case s.param != "":
+			if s.last() && i != len(segs)-1 {
+				return fmt.Sprintf("no match: segment %d captures the rest but is not last", i+1)
+			}
+			if s.req && argv[i] == "" {
+				return fmt.Sprintf("no match: segment %d is required param <%s>, got empty", i+1, s.param)
+			}
+			if s.noFlags && isFlagLike(argv[i]) {
+				return fmt.Sprintf("no match: segment %d is param <%s>, which refuses flags, got %q", i+1, s.param, argv[i])
+			}
+			if !s.matches(argv[i]) {
+				return fmt.Sprintf("no match: segment %d is list param <%s+>, got flag %q", i+1, s.param, argv[i])
+			}
+			codes.WriteByte('P')
+		default:
+			return fmt.Sprintf("no match: segment %d is empty", i+1)
+		}
+	}
+	if rt.leavesExtra(argv) {
+		return "no match: exact route leaves extra args"
+	}
+	return "rank " + codes.String()
+}
@@ -0,0 +1,35 @@
+package clir
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRouter_Explain(t *testing.T) {
+	r := New()
+	noop := func(*Request) error { return nil }
+	r.Handle("users <id>", "Show user", noop)
+	r.Handle("users me", "Show me", noop)
+	r.Handle("groups", "List groups", noop)
+	r.Handle("users <id> delete", "Delete user", noop)
+
+	var buf bytes.Buffer
+	r.Explain([]string{"users", "me"}, &buf)
+
+	want := "Explain `users me`:\n" +
+		"  1. users <id>         rank LP\n" +
+		"  2. users me           rank LL\n" +
+		"  3. groups             no match: segment 1 is literal \"groups\", got \"users\"\n" +
+		"  4. users <id> delete  no match: needs 3 args, got 2\n" +
+		"Selected: users me\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected explanation:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	r.Explain([]string{"nope"}, &buf)
+	if got := buf.String(); !strings.HasSuffix(got, "Selected: none\n") {
+		t.Fatalf("unexpected explanation: %q", got)
+	}
+}
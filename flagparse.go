@@ -0,0 +1,328 @@
+package clir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FlagSpec declares a flag for Request.ParseFlags.
+type FlagSpec struct {
+	// Name is the long name, used as --name. Optional if Short is set.
+	Name string
+
+	// Short is the single-character name, used as -s. Optional.
+	Short string
+
+	// Bool marks a presence flag that takes no value. Only boolean short
+	// flags may appear in the middle of a bundle like -abc.
+	Bool bool
+
+	// Default is the value Flags.String and Flags.Bool report when the
+	// flag isn't given. Optional.
+	Default string
+
+	// Desc describes the flag in per-command help. Optional.
+	Desc string
+
+	// Required makes ParseFlags fail when the flag isn't given, and shows
+	// it without brackets in the usage line.
+	Required bool
+}
+
+// key returns the name values are stored under: Name if set, else Short.
+func (s FlagSpec) key() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Short
+}
+
+// display returns the flag as typed: --name if it has a long name, else
+// -s.
+func (s FlagSpec) display() string {
+	if s.Name != "" {
+		return "--" + s.Name
+	}
+	return "-" + s.Short
+}
+
+// Flags holds the result of Request.ParseFlags.
+type Flags struct {
+	specs  map[string]FlagSpec // by long and short name
+	values map[string][]string // by FlagSpec.key, one entry per occurrence
+	args   []string
+}
+
+// lookup resolves a long or short name to its canonical key.
+func (f *Flags) lookup(name string) string {
+	if s, ok := f.specs[name]; ok {
+		return s.key()
+	}
+	return name
+}
+
+// Bool reports whether the boolean flag is set. The last occurrence wins,
+// so --x --x=false is false. If the flag isn't given, its default applies.
+func (f *Flags) Bool(name string) bool {
+	v, _ := f.String(name)
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+// String returns the last value given for the flag and reports whether
+// it was given. If not, it returns the flag's default.
+func (f *Flags) String(name string) (string, bool) {
+	vs := f.values[f.lookup(name)]
+	if len(vs) == 0 {
+		return f.specs[name].Default, false
+	}
+	return vs[len(vs)-1], true
+}
+
+// Values returns every value given for the flag, in order.
+func (f *Flags) Values(name string) []string {
+	return f.values[f.lookup(name)]
+}
+
+// Count returns how many times the flag occurred (-vvv counts 3).
+func (f *Flags) Count(name string) int {
+	return len(f.values[f.lookup(name)])
+}
+
+// Args returns the non-flag arguments, including everything after "--".
+func (f *Flags) Args() []string {
+	return f.args
+}
+
+// ParseFlags parses Extra against the declared flags, POSIX style:
+//
+//   - --name, --name=value and --name value for long flags
+//   - -s, -s value for short flags
+//   - bundles like -abc meaning -a -b -c, where all but the last must be
+//     boolean; a non-boolean last flag takes the next token as its value
+//     (-xo file)
+//   - "--" ends flag parsing; the rest are arguments
+//
+// Unknown flags, missing values, non-boolean flags in the middle of a
+// bundle and missing Required flags are errors. Parsing stops at the
+// first malformed token, but every missing Required flag is reported,
+// joined into one error. Extra itself is not modified.
+//
+// Example:
+//
+//	flags, err := req.ParseFlags(
+//	    clir.FlagSpec{Name: "verbose", Short: "v", Bool: true},
+//	    clir.FlagSpec{Name: "output", Short: "o"},
+//	)
+//	// argv: build -vo out.bin main.go
+//	// flags.Bool("v") == true, flags.String("output") == "out.bin",
+//	// flags.Args() == ["main.go"]
+func (r *Request) ParseFlags(specs ...FlagSpec) (*Flags, error) {
+	f, _, err := parseFlags(r.Extra, specs, false)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, s := range specs {
+		if s.Required && len(f.values[s.key()]) == 0 {
+			errs = append(errs, fmt.Errorf("missing required flag %s", s.display()))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ParseRouteFlags is ParseFlags with the flags declared for the matched
+// route with Builder.Flags.
+func (r *Request) ParseRouteFlags() (*Flags, error) {
+	var specs []FlagSpec
+	if r.route != nil {
+		specs = r.route.flags
+	}
+	return r.ParseFlags(specs...)
+}
+
+// Flags declares flags for routes handled by the returned builder,
+// including those in nested Route calls, in addition to any declared
+// before. Per-command help lists them in a "Flags:" section, and handlers
+// parse them with Request.ParseRouteFlags.
+//
+// Example:
+//
+//	b.Flags(
+//	    clir.FlagSpec{Name: "tag", Short: "t", Default: "latest", Desc: "image tag"},
+//	    clir.FlagSpec{Name: "push", Bool: true, Desc: "push after building"},
+//	).Handle("image build", "Build images", handler)
+func (b *Builder) Flags(specs ...FlagSpec) *Builder {
+	child := b.derive()
+	child.flags = append(child.flags, specs...)
+	return child
+}
+
+// Flags declares flags for routes handled by the returned typed builder.
+// See Builder.Flags.
+func (b *ContextBuilder[T]) Flags(specs ...FlagSpec) *ContextBuilder[T] {
+	return &ContextBuilder[T]{
+		base:     b.base.Flags(specs...),
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: b.resolved,
+	}
+}
+
+// flagSynopsis renders specs for the usage line in declaration order,
+// e.g. "[--tag TAG] [--push]". Value flags show their name in upper case
+// as a placeholder, and required flags go without brackets.
+func flagSynopsis(specs []FlagSpec) string {
+	parts := make([]string, len(specs))
+	for i, s := range specs {
+		part := s.display()
+		if !s.Bool {
+			part += " " + strings.ToUpper(strings.ReplaceAll(s.key(), "-", "_"))
+		}
+		if !s.Required {
+			part = "[" + part + "]"
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, " ")
+}
+
+// printFlags prints the "Flags:" section of per-command help, or nothing
+// if rt declares no flags. Value flags show "string" as their type, and
+// defaults are shown after the description.
+func printFlags(w io.Writer, rt *route, color bool) {
+	if len(rt.flags) == 0 {
+		return
+	}
+
+	names := make([]string, len(rt.flags))
+	maxLen := 0
+	for i, s := range rt.flags {
+		var parts []string
+		if s.Short != "" {
+			parts = append(parts, "-"+s.Short)
+		}
+		if s.Name != "" {
+			parts = append(parts, "--"+s.Name)
+		}
+		names[i] = strings.Join(parts, ", ")
+		if s.Short == "" {
+			names[i] = "    " + names[i]
+		}
+		if !s.Bool {
+			names[i] += " string"
+		}
+		maxLen = max(maxLen, len(names[i]))
+	}
+
+	fmt.Fprintf(w, "\n%s\n", bold("Flags:", color))
+	for i, s := range rt.flags {
+		desc := s.Desc
+		if s.Default != "" {
+			desc = strings.TrimSpace(fmt.Sprintf("%s (default %q)", desc, s.Default))
+		}
+		if desc == "" {
+			fmt.Fprintf(w, "  %s\n", names[i])
+			continue
+		}
+		pad := strings.Repeat(" ", maxLen-len(names[i]))
+		fmt.Fprintf(w, "  %s%s  %s\n", names[i], pad, desc)
+	}
+}
+
+// parseFlags implements ParseFlags over tokens. With leading set, parsing
+// stops at the first argument and the unparsed tokens are returned as
+// rest; otherwise all tokens are parsed and rest is nil.
+func parseFlags(tokens []string, specs []FlagSpec, leading bool) (f *Flags, rest []string, err error) {
+	f = &Flags{
+		specs:  map[string]FlagSpec{},
+		values: map[string][]string{},
+	}
+	for _, s := range specs {
+		if s.Name != "" {
+			f.specs[s.Name] = s
+		}
+		if s.Short != "" {
+			f.specs[s.Short] = s
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		switch {
+		case tok == "--":
+			if leading {
+				return f, tokens[i+1:], nil
+			}
+			f.args = append(f.args, tokens[i+1:]...)
+			return f, nil, nil
+
+		case strings.HasPrefix(tok, "--"):
+			name, value, hasValue := strings.Cut(tok[2:], "=")
+			spec, ok := f.specs[name]
+			if !ok || spec.Name != name {
+				return nil, nil, fmt.Errorf("unknown flag --%s", name)
+			}
+			if spec.Bool {
+				if !hasValue {
+					value = "true"
+				} else if _, err := strconv.ParseBool(value); err != nil {
+					return nil, nil, fmt.Errorf("invalid value %q for boolean flag --%s", value, name)
+				}
+			} else if !hasValue {
+				if i+1 >= len(tokens) {
+					return nil, nil, fmt.Errorf("flag --%s requires a value", name)
+				}
+				i++
+				value = tokens[i]
+			}
+			f.values[spec.key()] = append(f.values[spec.key()], value)
+
+		case isShortCluster(tok):
+			cluster := tok[1:]
+			for j, c := range cluster {
+				name := string(c)
+				spec, ok := f.specs[name]
+				if !ok || spec.Short != name {
+					return nil, nil, fmt.Errorf("unknown flag -%s in %s", name, tok)
+				}
+				if spec.Bool {
+					f.values[spec.key()] = append(f.values[spec.key()], "true")
+					continue
+				}
+				if j != len(cluster)-1 {
+					return nil, nil, fmt.Errorf("flag -%s in %s takes a value and must come last in the bundle", name, tok)
+				}
+				if i+1 >= len(tokens) {
+					return nil, nil, fmt.Errorf("flag -%s requires a value", name)
+				}
+				i++
+				f.values[spec.key()] = append(f.values[spec.key()], tokens[i])
+			}
+
+		case isFlagLike(tok):
+			return nil, nil, fmt.Errorf("unknown flag %s", tok)
+
+		default:
+			if leading {
+				return f, tokens[i:], nil
+			}
+			f.args = append(f.args, tok)
+		}
+	}
+	return f, nil, nil
+}
+
+// isFlagLike reports whether tok starts like a flag. A lone "-" and
+// negative numbers are arguments.
+func isFlagLike(tok string) bool {
+	_, _, _, ok := flagName(tok)
+	return ok
+}
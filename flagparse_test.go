@@ -0,0 +1,243 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var testFlagSpecs = []FlagSpec{
+	{Name: "all", Short: "a", Bool: true},
+	{Name: "brief", Short: "b", Bool: true},
+	{Short: "v", Bool: true},
+	{Name: "output", Short: "o"},
+	{Name: "tag"},
+}
+
+func TestRequest_ParseFlags(t *testing.T) {
+	req := &Request{Extra: []string{
+		"-ab", "src", "-vvv", "--tag=v1", "--tag", "v2", "-avo", "out.bin", "-1", "--brief=false", "--", "-a",
+	}}
+
+	f, err := req.ParseFlags(testFlagSpecs...)
+	if err != nil {
+		t.Fatalf("ParseFlags returned error: %v", err)
+	}
+
+	if !f.Bool("all") || !f.Bool("a") {
+		t.Fatal("expected --all to be set")
+	}
+	if f.Bool("brief") {
+		t.Fatal("expected --brief=false to win")
+	}
+	if got := f.Count("v"); got != 4 {
+		t.Fatalf("Count(v) = %d, want 4", got)
+	}
+	if got, _ := f.String("o"); got != "out.bin" {
+		t.Fatalf("String(o) = %q, want out.bin", got)
+	}
+	if got := fmt.Sprint(f.Values("tag")); got != "[v1 v2]" {
+		t.Fatalf("Values(tag) = %s", got)
+	}
+	if _, ok := f.String("missing"); ok {
+		t.Fatal("String(missing) should not be set")
+	}
+	if got := fmt.Sprint(f.Args()); got != "[src -1 -a]" {
+		t.Fatalf("Args() = %s", got)
+	}
+	if len(req.Extra) != 12 {
+		t.Fatalf("Extra was modified: %v", req.Extra)
+	}
+}
+
+func TestRequest_ParseFlags_Errors(t *testing.T) {
+	tests := []struct {
+		extra []string
+		want  string
+	}{
+		{[]string{"-aob"}, "flag -o in -aob takes a value and must come last in the bundle"},
+		{[]string{"-az"}, "unknown flag -z in -az"},
+		{[]string{"--nope"}, "unknown flag --nope"},
+		{[]string{"--v"}, "unknown flag --v"},
+		{[]string{"-x1"}, "unknown flag -x1"},
+		{[]string{"--output"}, "flag --output requires a value"},
+		{[]string{"-ao"}, "flag -o requires a value"},
+		{[]string{"--all=maybe"}, `invalid value "maybe" for boolean flag --all`},
+	}
+
+	for _, tt := range tests {
+		req := &Request{Extra: tt.extra}
+		_, err := req.ParseFlags(testFlagSpecs...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseFlags(%v) error = %v, want %q", tt.extra, err, tt.want)
+		}
+	}
+}
+
+func TestRouter_SetGlobalFlags(t *testing.T) {
+	r := New()
+	r.SetGlobalFlags(
+		FlagSpec{Name: "verbose", Short: "v", Bool: true},
+		FlagSpec{Name: "config"},
+	)
+
+	var got *Request
+	r.Handle("comp <component> image build", "Build images", func(req *Request) error {
+		got = req
+		return nil
+	})
+
+	argv := []string{"-v", "--config", "dev.yaml", "comp", "api", "image", "build", "--push"}
+	if err := r.Run(context.Background(), argv); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if !got.Globals.Bool("verbose") {
+		t.Fatal("expected global --verbose to be set")
+	}
+	if cfg, _ := got.Globals.String("config"); cfg != "dev.yaml" {
+		t.Fatalf("unexpected --config: %q", cfg)
+	}
+	if got.Params["component"] != "api" || fmt.Sprint(got.Extra) != "[--push]" {
+		t.Fatalf("unexpected match: params=%v extra=%v", got.Params, got.Extra)
+	}
+	if len(got.Args) != len(argv) {
+		t.Fatalf("Args should be the full argv, got %v", got.Args)
+	}
+}
+
+func TestRouter_SetGlobalFlags_UnknownLeadingFlag(t *testing.T) {
+	r := New()
+	r.SetGlobalFlags(FlagSpec{Name: "verbose", Bool: true})
+	r.Handle("comp <component> image build", "Build images", func(req *Request) error { return nil })
+
+	err := r.Run(context.Background(), []string{"--verbos", "comp", "x", "image", "build"})
+	if err == nil || err.Error() != "unknown flag --verbos" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRouter_LeadingFlag_NoMatchWithoutGlobalFlags(t *testing.T) {
+	r := New()
+	r.Handle("comp <component> image build", "Build images", func(req *Request) error { return nil })
+
+	err := r.Run(context.Background(), []string{"--verbos", "comp", "x", "image", "build"})
+	if err == nil || !strings.Contains(err.Error(), "no matching command") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFlags_Default(t *testing.T) {
+	req := &Request{Extra: []string{"-t", "v1"}}
+	f, err := req.ParseFlags(
+		FlagSpec{Name: "tag", Short: "t", Default: "latest"},
+		FlagSpec{Name: "registry", Default: "docker.io"},
+		FlagSpec{Name: "push", Bool: true, Default: "true"},
+	)
+	if err != nil {
+		t.Fatalf("ParseFlags returned error: %v", err)
+	}
+	if v, ok := f.String("tag"); v != "v1" || !ok {
+		t.Fatalf("String(tag) = %q, %v", v, ok)
+	}
+	if v, ok := f.String("registry"); v != "docker.io" || ok {
+		t.Fatalf("String(registry) = %q, %v; want the default, not given", v, ok)
+	}
+	if !f.Bool("push") {
+		t.Fatal("expected --push to default to true")
+	}
+	if f.Count("registry") != 0 || f.Values("registry") != nil {
+		t.Fatal("defaults should not count as occurrences")
+	}
+}
+
+func TestBuilder_Flags(t *testing.T) {
+	r := New()
+	r.SetName("mytool")
+	r.EnableHelpCommand(true)
+
+	var out bytes.Buffer
+	r.SetOutput(&out, &out)
+
+	var tag string
+	r.Routes(func(b *Builder) {
+		b.Flags(FlagSpec{Name: "verbose", Short: "v", Bool: true, Desc: "verbose output"}).Route("image", func(b *Builder) {
+			b.Flags(
+				FlagSpec{Name: "tag", Short: "t", Default: "latest", Desc: "image tag"},
+				FlagSpec{Name: "push", Bool: true},
+				FlagSpec{Name: "platform"},
+			).Handle("build", "Build images", func(req *Request) error {
+				f, err := req.ParseRouteFlags()
+				if err != nil {
+					return err
+				}
+				tag, _ = f.String("tag")
+				return nil
+			})
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"image", "build", "-v", "--push"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if tag != "latest" {
+		t.Fatalf("tag = %q, want the default", tag)
+	}
+	if err := r.Run(context.Background(), []string{"image", "build", "--puhs"}); err == nil || err.Error() != "unknown flag --puhs" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"help", "image", "build"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := `Usage: mytool image build [--verbose] [--tag TAG] [--push] [--platform PLATFORM]
+
+Build images
+
+Flags:
+  -v, --verbose          verbose output
+  -t, --tag string       image tag (default "latest")
+      --push
+      --platform string
+`
+	if out.String() != want {
+		t.Fatalf("unexpected command help:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRequest_ParseFlags_Required(t *testing.T) {
+	r, out := newHelpRouter()
+	r.EnableHelpCommand(true)
+	r.Routes(func(b *Builder) {
+		b.Flags(
+			FlagSpec{Name: "tag", Short: "t", Required: true},
+			FlagSpec{Name: "dry-run", Bool: true},
+			FlagSpec{Short: "o"},
+		).Handle("release", "Cut a release", func(req *Request) error {
+			_, err := req.ParseRouteFlags()
+			return err
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"release", "-t", "v1"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if err := r.Run(context.Background(), []string{"release", "--dry-run"}); err == nil || err.Error() != "missing required flag --tag" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := (&Request{}).ParseFlags(FlagSpec{Name: "tag", Required: true}, FlagSpec{Name: "env", Required: true})
+	if err == nil || err.Error() != "missing required flag --tag\nmissing required flag --env" {
+		t.Fatalf("expected every missing flag to be reported, got: %v", err)
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"help", "release"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got, _, _ := strings.Cut(out.String(), "\n"); got != "Usage: mytool release --tag TAG [--dry-run] [-o O]" {
+		t.Fatalf("unexpected usage line: %q", got)
+	}
+}
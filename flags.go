@@ -0,0 +1,254 @@
+package clir
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// flagName returns the name of a flag token ("--tag=x" => "tag") and
+// whether the token is a flag at all. Both "-name" and "--name" are
+// accepted, like the standard flag package. "-", "--" and negative
+// numbers such as "-1" are not flags.
+func flagName(tok string) (name, value string, hasValue, ok bool) {
+	if len(tok) < 2 || tok[0] != '-' || tok == "--" {
+		return "", "", false, false
+	}
+	name = strings.TrimPrefix(tok[1:], "-")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return "", "", false, false
+	}
+	if i := strings.IndexByte(name, '='); i >= 0 {
+		return name[:i], name[i+1:], true, true
+	}
+	return name, "", false, true
+}
+
+// Flag scans Extra for --name value or --name=value and returns the value
+// of the first occurrence. A following token that looks like a flag is
+// not taken as the value. Scanning stops at a "--" terminator.
+//
+// Flag only reads Extra; it never consumes or reorders it.
+//
+// Example:
+//
+//	// argv: comp api image build --tag latest --push
+//	tag, ok := req.Flag("tag") // "latest", true
+func (r *Request) Flag(name string) (string, bool) {
+	for i := 0; i < len(r.Extra); i++ {
+		tok := r.Extra[i]
+		if tok == "--" {
+			break
+		}
+		n, v, hasValue, ok := flagName(tok)
+		if !ok || n != name {
+			continue
+		}
+		if hasValue {
+			return v, true
+		}
+		if i+1 < len(r.Extra) {
+			next := r.Extra[i+1]
+			if _, _, _, isFlag := flagName(next); !isFlag && next != "--" {
+				return next, true
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// HasFlag reports whether --name (or --name=value) is present in Extra
+// before any "--" terminator. Use it for boolean presence flags like --push.
+func (r *Request) HasFlag(name string) bool {
+	for _, tok := range r.Extra {
+		if tok == "--" {
+			break
+		}
+		if n, _, _, ok := flagName(tok); ok && n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Positionals returns the non-flag tokens of Extra, in order. A lone "-"
+// and negative numbers count as positionals. Everything after a "--"
+// terminator is positional, including tokens starting with "-". Run moves
+// those tokens to PassThrough, so Positionals returns PassThrough after
+// the positionals of Extra: "cp -v -- -src dst" gives [-src dst].
+//
+// Values of flags written as --name value are not recognized as such
+// and are returned as positionals; prefer --name=value in that case.
+func (r *Request) Positionals() []string {
+	var out []string
+	for i, tok := range r.Extra {
+		if tok == "--" {
+			out = append(out, r.Extra[i+1:]...)
+			break
+		}
+		if _, _, _, ok := flagName(tok); !ok {
+			out = append(out, tok)
+		}
+	}
+	return append(out, r.PassThrough...)
+}
+
+// Positional returns the i-th positional (see Positionals).
+//
+// Example:
+//
+//	// pattern: "cp", argv: cp --force src.txt dst.txt
+//	src, _ := req.Positional(0) // "src.txt"
+//	dst, _ := req.Positional(1) // "dst.txt"
+func (r *Request) Positional(i int) (string, bool) {
+	pos := r.Positionals()
+	if i < 0 || i >= len(pos) {
+		return "", false
+	}
+	return pos[i], true
+}
+
+// FlagRule is a constraint on which flags may appear together. It gets
+// the set of flag names present in Extra and returns an error naming the
+// offending flags. See Request.CheckFlags.
+type FlagRule func(present map[string]bool) error
+
+// MutuallyExclusive returns a rule that fails when more than one of the
+// named flags is present.
+func MutuallyExclusive(names ...string) FlagRule {
+	return func(present map[string]bool) error {
+		var set []string
+		for _, n := range names {
+			if present[n] {
+				set = append(set, "--"+n)
+			}
+		}
+		if len(set) > 1 {
+			return fmt.Errorf("flags %s are mutually exclusive", strings.Join(set, ", "))
+		}
+		return nil
+	}
+}
+
+// RequiredTogether returns a rule that fails when some, but not all, of
+// the named flags are present.
+func RequiredTogether(names ...string) FlagRule {
+	return func(present map[string]bool) error {
+		var set, missing []string
+		for _, n := range names {
+			if present[n] {
+				set = append(set, "--"+n)
+			} else {
+				missing = append(missing, "--"+n)
+			}
+		}
+		if len(set) > 0 && len(missing) > 0 {
+			return fmt.Errorf("%s requires %s", strings.Join(set, ", "), strings.Join(missing, ", "))
+		}
+		return nil
+	}
+}
+
+// CheckFlags scans Extra once and evaluates the rules against the flags
+// present. All violations are reported, joined into a single error.
+//
+// Example:
+//
+//	if err := req.CheckFlags(
+//	    clir.MutuallyExclusive("json", "yaml"),
+//	    clir.RequiredTogether("cert", "key"),
+//	); err != nil {
+//	    return err // e.g. "flags --json, --yaml are mutually exclusive"
+//	}
+func (r *Request) CheckFlags(rules ...FlagRule) error {
+	present := map[string]bool{}
+	for _, tok := range r.Extra {
+		if tok == "--" {
+			break
+		}
+		if name, _, _, ok := flagName(tok); ok {
+			present[name] = true
+		}
+	}
+
+	var errs []error
+	for _, rule := range rules {
+		if err := rule(present); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RejectUnknownFlags returns an error naming every flag in Extra that is
+// not in allowed, or nil if there are none. Flags are matched by name as
+// in HasFlag, so "-tag" and "--tag" are both allowed by "tag". Scanning
+// stops at "--". It is opt-in per handler, for commands that want typos
+// reported instead of ignored.
+//
+// Example:
+//
+//	// argv: image build --tag v1 --puhs
+//	err := req.RejectUnknownFlags("tag", "push", "registry")
+//	// err: unknown flag --puhs
+func (r *Request) RejectUnknownFlags(allowed ...string) error {
+	var errs []error
+	seen := map[string]bool{}
+	for _, tok := range r.Extra {
+		if tok == "--" {
+			break
+		}
+		name, _, _, ok := flagName(tok)
+		if !ok || seen[name] || slices.Contains(allowed, name) {
+			continue
+		}
+		seen[name] = true
+		flag, _, _ := strings.Cut(tok, "=")
+		errs = append(errs, fmt.Errorf("unknown flag %s", flag))
+	}
+	return errors.Join(errs...)
+}
+
+// FlagCount returns how often the single-character flag name occurs in
+// Extra, counting bundled forms: "-v -v" and "-vv" both count 2, "-vx"
+// counts 1. Every single-dash token of letters is read as a bundle, so a
+// single-dash long flag counts its letters too: "-verbose" counts 2 for
+// "e", even though HasFlag sees it as "verbose". Double-dash long flags
+// (--verbose) never count. Scanning stops at "--".
+//
+// Example:
+//
+//	// argv: deploy -vvv
+//	verbosity := req.FlagCount("v") // 3
+func (r *Request) FlagCount(name string) int {
+	if len(name) != 1 {
+		return 0
+	}
+	n := 0
+	for _, tok := range r.Extra {
+		if tok == "--" {
+			break
+		}
+		if !isShortCluster(tok) {
+			continue
+		}
+		n += strings.Count(tok[1:], name)
+	}
+	return n
+}
+
+// isShortCluster reports whether tok is a single-dash token of one or more
+// short flag letters, like "-v" or "-abc".
+func isShortCluster(tok string) bool {
+	if len(tok) < 2 || tok[0] != '-' || tok[1] == '-' {
+		return false
+	}
+	for _, c := range tok[1:] {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package clir
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestRequest_Flag(t *testing.T) {
+	req := &Request{Extra: []string{
+		"--tag", "latest", "--push", "--env=prod", "-n", "3", "--empty=", "--last",
+	}}
+
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"tag", "latest", true},
+		{"env", "prod", true},
+		{"n", "3", true},
+		{"empty", "", true},
+		{"push", "", false}, // followed by a flag, so no value
+		{"last", "", false}, // no following token
+		{"missing", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := req.Flag(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Flag(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if fmt.Sprint(req.Extra) != "[--tag latest --push --env=prod -n 3 --empty= --last]" {
+		t.Fatalf("Extra was modified: %v", req.Extra)
+	}
+}
+
+func TestRequest_HasFlag(t *testing.T) {
+	req := &Request{Extra: []string{"--push", "--tag=v1", "-1", "--", "--after"}}
+
+	for name, want := range map[string]bool{
+		"push":  true,
+		"tag":   true,
+		"1":     false,
+		"after": false, // after the terminator
+		"":      false,
+	} {
+		if got := req.HasFlag(name); got != want {
+			t.Errorf("HasFlag(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestRequest_Positionals(t *testing.T) {
+	req := &Request{Extra: []string{"--force", "src.txt", "-", "-1", "dst.txt", "--", "--not-a-flag", "x"}}
+
+	want := "[src.txt - -1 dst.txt --not-a-flag x]"
+	if got := fmt.Sprint(req.Positionals()); got != want {
+		t.Fatalf("Positionals() = %v, want %v", got, want)
+	}
+
+	// Run moves the tokens after "--" to PassThrough; they stay positional.
+	r := New()
+	r.Handle("cp", "Copy", func(req *Request) error {
+		if got := fmt.Sprint(req.Positionals()); got != "[-src dst]" {
+			t.Errorf("Positionals() after Run = %v, want [-src dst]", got)
+		}
+		return nil
+	})
+	if err := r.Run(context.Background(), []string{"cp", "-verbose", "--", "-src", "dst"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if got, ok := req.Positional(0); !ok || got != "src.txt" {
+		t.Fatalf("Positional(0) = %q, %v", got, ok)
+	}
+	if got, ok := req.Positional(4); !ok || got != "--not-a-flag" {
+		t.Fatalf("Positional(4) = %q, %v", got, ok)
+	}
+	if _, ok := req.Positional(6); ok {
+		t.Fatal("Positional(6) should be out of range")
+	}
+	if _, ok := req.Positional(-1); ok {
+		t.Fatal("Positional(-1) should be out of range")
+	}
+}
+
+func TestRequest_CheckFlags(t *testing.T) {
+	rules := []FlagRule{
+		MutuallyExclusive("json", "yaml"),
+		RequiredTogether("cert", "key"),
+	}
+
+	tests := []struct {
+		extra []string
+		want  string
+	}{
+		{[]string{"--json"}, ""},
+		{[]string{"--cert", "c.pem", "--key=k.pem"}, ""},
+		{[]string{"--json", "--yaml"}, "flags --json, --yaml are mutually exclusive"},
+		{[]string{"--cert", "c.pem"}, "--cert requires --key"},
+		{[]string{"--json", "--", "--yaml"}, ""},
+		{
+			[]string{"--yaml", "--key", "k.pem", "--json"},
+			"flags --json, --yaml are mutually exclusive\n--key requires --cert",
+		},
+	}
+
+	for _, tt := range tests {
+		req := &Request{Extra: tt.extra}
+		err := req.CheckFlags(rules...)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("CheckFlags(%v) = %q, want %q", tt.extra, got, tt.want)
+		}
+	}
+}
+
+func TestRequest_FlagCount(t *testing.T) {
+	req := &Request{Extra: []string{"-vvv", "--verbose", "-v", "-xv", "-v=2", "-1", "--", "-v"}}
+
+	if got := req.FlagCount("v"); got != 5 {
+		t.Fatalf("FlagCount(v) = %d, want 5", got)
+	}
+	if got := req.FlagCount("x"); got != 1 {
+		t.Fatalf("FlagCount(x) = %d, want 1", got)
+	}
+	if got := req.FlagCount("verbose"); got != 0 {
+		t.Fatalf("FlagCount(verbose) = %d, want 0", got)
+	}
+
+	// A single-dash long flag reads as a bundle of its letters.
+	req = &Request{Extra: []string{"-verbose"}}
+	if !req.HasFlag("verbose") {
+		t.Fatal("HasFlag(verbose) = false for -verbose")
+	}
+	if got := req.FlagCount("e"); got != 2 {
+		t.Fatalf("FlagCount(e) = %d for -verbose, want 2", got)
+	}
+}
+
+func TestRequest_RejectUnknownFlags(t *testing.T) {
+	tests := []struct {
+		extra []string
+		want  string
+	}{
+		{[]string{"--tag", "v1", "--push", "-registry=x", "arg"}, ""},
+		{[]string{"--tag", "v1", "--puhs"}, "unknown flag --puhs"},
+		{[]string{"--puhs", "--regsitry=x", "--puhs", "-1"}, "unknown flag --puhs\nunknown flag --regsitry"},
+		{[]string{"--", "--anything"}, ""},
+	}
+
+	for _, tt := range tests {
+		req := &Request{Extra: tt.extra}
+		err := req.RejectUnknownFlags("tag", "push", "registry")
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("RejectUnknownFlags(%v) = %q, want %q", tt.extra, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package clir
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func FuzzBestMatch(f *testing.F) {
+	f.Add("users <id>\nusers me\nusers <id> delete", "users 42 delete --force")
+	f.Add("comp <component> image build\ncomp <component> image <image>", "comp api image web")
+	f.Add("log *\nlog v?\nlog v* tail\nstash@{*} drop", "log v1 tail")
+	f.Add("exec <cmd...>\nexec ls <dir>\nrun <cmd>", "exec ls -la --color")
+	f.Add("set <key>=<value>\nset env=*\nset <k>=<v> now", "set region=eu now")
+	f.Add("tag add <tags+>\ntag add x <more+>", "tag add a b --push c")
+	f.Add("2 sorted <x>\n<any> <more>\n\\3 x", "sorted 1")
+	f.Add(strings.Repeat("a ", 40)+"<x>", strings.Repeat("a ", 41))
+
+	f.Fuzz(func(t *testing.T, patterns, line string) {
+		r := New()
+		for _, p := range strings.Split(patterns, "\n") {
+			func() {
+				defer func() { recover() }() // invalid patterns panic by design
+				r.Handle(p, "desc", func(*Request) error { return nil })
+			}()
+		}
+		argv := strings.Fields(line)
+
+		wantRt, wantReq, wantOK := r.bestMatchLinear(context.Background(), argv)
+		rt, req, ok := r.bestMatch(context.Background(), argv)
+		if ok != wantOK || rt != wantRt {
+			t.Fatalf("trie and linear match disagree for %q", argv)
+		}
+		if !ok {
+			return
+		}
+		if !slices.Equal(req.Extra, wantReq.Extra) {
+			t.Fatalf("extra mismatch: got %q, want %q", req.Extra, wantReq.Extra)
+		}
+		for i, s := range rt.segments {
+			if s.last() {
+				break
+			}
+			if i >= len(argv) {
+				t.Fatalf("route %q matched %q with too few tokens", rt.String(), argv)
+			}
+			switch {
+			case s.lit != "" && argv[i] != s.lit:
+				t.Fatalf("route %q: literal %q matched token %q", rt.String(), s.lit, argv[i])
+			case s.glob != "" && s.key == "" && !globMatch(s.glob, argv[i]):
+				t.Fatalf("route %q: glob %q matched token %q", rt.String(), s.glob, argv[i])
+			case s.param != "" && req.Params[s.param] != argv[i]:
+				t.Fatalf("route %q: param <%s> = %q, token %q", rt.String(), s.param, req.Params[s.param], argv[i])
+			}
+		}
+	})
+}
+
+func FuzzTokenize(f *testing.F) {
+	f.Add(`comp api deploy --msg "release 1.2"`)
+	f.Add(`--msg="hi there" '' "" a\ b`)
+	f.Add(`"a \" b \\ c" 'it''s'`)
+	f.Add(`trailing\`)
+	f.Add(`"unterminated`)
+
+	quote := func(arg string) string {
+		return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		args, err := SplitLine(line)
+		if err != nil {
+			return
+		}
+		quoted := make([]string, len(args))
+		for i, a := range args {
+			quoted[i] = quote(a)
+		}
+		again, err := SplitLine(strings.Join(quoted, " "))
+		if err != nil || !slices.Equal(again, args) {
+			t.Fatalf("re-quoted %q split into %q (%v), want %q", line, again, err, args)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package clir
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// CommandHandle refers to a registered route, as returned by Handle, so
+// help metadata can be attached fluently after registration:
+//
+//	r.Handle("debug dump", "Dump internal state", dump).Hidden()
+//	r.Handle("comp <component> image build", "Build images", build).
+//	    Example("mytool comp api image build --tag v1").
+//	    Example("mytool comp web image build")
+//
+// Its methods panic after Router.Freeze, like Handle itself.
+type CommandHandle struct {
+	router *Router
+}
+
+// Hidden leaves the command out of PrintHelp, group help and
+// Request.Subcommands. It still runs, and "help <command>" still
+// describes it.
+func (h *CommandHandle) Hidden() *CommandHandle {
+	h.update("Hidden", func(rt *route) { rt.hidden = true })
+	return h
+}
+
+// Example adds a usage example, shown under "Examples:" in the
+// command's help. Call it once per example.
+func (h *CommandHandle) Example(line string) *CommandHandle {
+	h.update("Example", func(rt *route) { rt.examples = append(rt.examples, line) })
+	return h
+}
+
+// Deprecated marks the command as deprecated with a message, e.g.
+// `use "comp <component> image build" instead`. Running it still works
+// but first prints a warning to Request.Stderr; help shows the message.
+func (h *CommandHandle) Deprecated(msg string) *CommandHandle {
+	h.update("Deprecated", func(rt *route) { rt.deprecated = msg })
+	return h
+}
+
+// Group lists the command under a heading of its own in PrintHelp, e.g.
+// "Management Commands:" for Group("Management Commands"). Groups follow
+// the ungrouped commands, in order of first registration.
+func (h *CommandHandle) Group(name string) *CommandHandle {
+	h.update("Group", func(rt *route) { rt.group = name })
+	return h
+}
+
+// CommandOptions is the help metadata for HandleWith: the declarative
+// counterpart of Handle plus CommandHandle's methods.
+type CommandOptions struct {
+	// Desc is the one-line description, as passed to Handle.
+	Desc string
+
+	// Long is the detailed help text, see Builder.Long.
+	Long string
+
+	// Examples are usage examples, see CommandHandle.Example.
+	Examples []string
+
+	// Hidden leaves the command out of help listings, see
+	// CommandHandle.Hidden.
+	Hidden bool
+
+	// Deprecated is the deprecation message, see CommandHandle.Deprecated.
+	Deprecated string
+
+	// Aliases are alternative patterns, relative to the same prefix, that
+	// run the same handler. They are hidden from listings and named in
+	// the command's help. An alias is an ordinary route: it ranks like
+	// any other pattern of its shape, so "co <branch>" never beats a more
+	// specific "co list". Validate reports aliases that an earlier route
+	// of the same shape makes unreachable.
+	Aliases []string
+
+	// Group is the help heading, see CommandHandle.Group.
+	Group string
+
+	// Timeout is the default deadline, see Builder.Timeout.
+	Timeout time.Duration
+}
+
+// HandleWith is Handle with the help metadata given as CommandOptions.
+//
+// Example:
+//
+//	r.HandleWith("remote remove <name>", clir.CommandOptions{
+//	    Desc:    "Remove a remote",
+//	    Aliases: []string{"remote rm <name>"},
+//	    Group:   "Remotes",
+//	}, removeRemote)
+func (r *Router) HandleWith(pattern string, opts CommandOptions, h Handler) *CommandHandle {
+	return (&Builder{router: r}).HandleWith(pattern, opts, h)
+}
+
+// HandleWith registers a handler under the current prefix + path with the
+// help metadata given as CommandOptions. See Router.HandleWith.
+func (b *Builder) HandleWith(path string, opts CommandOptions, h Handler) *CommandHandle {
+	if opts.Long != "" {
+		b = b.Long(opts.Long)
+	}
+	if opts.Timeout > 0 {
+		b = b.Timeout(opts.Timeout)
+	}
+
+	meta := func(hd *CommandHandle) {
+		hd.update("HandleWith", func(rt *route) {
+			rt.examples = append(rt.examples, opts.Examples...)
+			rt.hidden = opts.Hidden
+			rt.deprecated = opts.Deprecated
+			rt.group = opts.Group
+		})
+	}
+
+	hd := b.handle(path, opts.Desc, h)
+	meta(hd)
+	var canonical string
+	hd.update("HandleWith", func(rt *route) { canonical = rt.String() })
+	for _, alias := range opts.Aliases {
+		ahd := b.handle(alias, opts.Desc, h)
+		meta(ahd)
+		var pat string
+		ahd.update("HandleWith", func(rt *route) {
+			rt.hidden = true
+			rt.aliasOf = canonical
+			pat = rt.String()
+		})
+		hd.update("HandleWith", func(rt *route) { rt.aliases = append(rt.aliases, pat) })
+	}
+	return hd
+}
+
+// update applies fn to the route h refers to.
+func (h *CommandHandle) update(op string, fn func(rt *route)) {
+	r := h.router
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen(op)
+
+	for i := range r.routes {
+		if r.routes[i].ref == h {
+			fn(&r.routes[i])
+			return
+		}
+	}
+}
+
+// printExamples prints the "Examples:" section of a command's help.
+func printExamples(w io.Writer, rt *route, color bool) {
+	if len(rt.examples) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n", bold("Examples:", color))
+	for _, ex := range rt.examples {
+		fmt.Fprintf(w, "  %s\n", strings.TrimSuffix(ex, "\n"))
+	}
+}
+
+// warnDeprecated prints the warning shown when a deprecated command runs.
+func warnDeprecated(w io.Writer, prog string, rt *route) {
+	fmt.Fprintf(w, "Warning: `%s` is deprecated: %s\n", joinProg(prog, rt.String()), rt.deprecated)
+}
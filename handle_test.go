@@ -0,0 +1,195 @@
+package clir
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandHandle_Hidden(t *testing.T) {
+	r, out := newHelpRouter()
+	r.EnableHelpCommand(true)
+	ran := false
+	r.Handle("debug dump", "Dump internal state", func(*Request) error {
+		ran = true
+		return nil
+	}).Hidden()
+
+	r.PrintHelp(out)
+	if strings.Contains(out.String(), "debug dump") {
+		t.Fatalf("hidden command listed in help:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"help", "debug", "dump"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "Usage: mytool debug dump\n") {
+		t.Fatalf("unexpected command help: %q", out.String())
+	}
+
+	if err := r.Run(context.Background(), []string{"debug", "dump"}); err != nil || !ran {
+		t.Fatalf("hidden command should still run: ran=%v err=%v", ran, err)
+	}
+
+	for _, c := range r.Commands() {
+		if c.Pattern == "debug dump" && !c.Hidden {
+			t.Fatal("Commands should report the command as hidden")
+		}
+	}
+}
+
+func TestCommandHandle_ExampleAndDeprecated(t *testing.T) {
+	r, out := newHelpRouter()
+	r.EnableHelpCommand(true)
+	r.Routes(func(b *Builder) {
+		b.Route("comp <component>", func(b *Builder) {
+			b.Handle("build", "Build images", func(*Request) error { return nil }).
+				Deprecated(`use "comp <component> image build" instead`).
+				Example("mytool comp api build").
+				Example("mytool comp web build")
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"help", "comp", "api", "build"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := "Usage: mytool comp <component> build\n\nBuild images\n\n" +
+		"Deprecated: use \"comp <component> image build\" instead\n\n" +
+		"Arguments:\n  <component>\n\n" +
+		"Examples:\n  mytool comp api build\n  mytool comp web build\n"
+	if got := out.String(); got != want {
+		t.Fatalf("unexpected command help:\n%q\nwant:\n%q", got, want)
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"comp", "api", "build"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := out.String(); got != "Warning: `mytool comp <component> build` is deprecated: use \"comp <component> image build\" instead\n" {
+		t.Fatalf("unexpected warning: %q", got)
+	}
+}
+
+func TestCommandHandle_PanicsAfterFreeze(t *testing.T) {
+	r := New()
+	h := r.Handle("version", "Show version", func(*Request) error { return nil })
+	r.Freeze()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected Hidden after Freeze to panic")
+		}
+	}()
+	h.Hidden()
+}
+
+func TestRouter_HandleWith(t *testing.T) {
+	r, out := newHelpRouter()
+	r.EnableHelpCommand(true)
+	var got *Request
+	r.HandleWith("remote remove <name>", CommandOptions{
+		Desc:     "Remove a remote",
+		Long:     "Remove a remote and its tracking branches.",
+		Examples: []string{"mytool remote rm origin"},
+		Aliases:  []string{"remote rm <name>"},
+		Group:    "Remotes",
+		Timeout:  time.Minute,
+	}, func(req *Request) error {
+		got = req
+		return nil
+	})
+
+	if err := r.Run(context.Background(), []string{"remote", "rm", "origin"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got == nil || got.Params["name"] != "origin" {
+		t.Fatalf("alias did not run the handler: %v", got)
+	}
+	if _, ok := got.Context().Deadline(); !ok {
+		t.Fatal("expected the timeout to apply to the alias")
+	}
+
+	out.Reset()
+	r.PrintHelp(out)
+	want := "Available commands:\n" +
+		"  comp <component> image build  Build images\n" +
+		"  comp <component> image push   Push images\n" +
+		"  help                          Show help for a command\n" +
+		"  version                       Show version\n" +
+		"\nRemotes:\n" +
+		"  remote remove <name>  Remove a remote\n"
+	if out.String() != want {
+		t.Fatalf("unexpected help:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"help", "remote", "remove", "x"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want = "Usage: mytool remote remove <name>\n" +
+		"Aliases: remote rm <name>\n\n" +
+		"Remove a remote and its tracking branches.\n\n" +
+		"Arguments:\n  <name>\n\n" +
+		"Examples:\n  mytool remote rm origin\n\n" +
+		"Timeout: 1m0s (override with --timeout)\n"
+	if out.String() != want {
+		t.Fatalf("unexpected command help:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
+
+func TestRouter_HandleWith_AliasRanking(t *testing.T) {
+	r := New()
+	var got string
+	handler := func(name string) Handler {
+		return func(req *Request) error {
+			got = name
+			return nil
+		}
+	}
+	r.Handle("co list", "List checkouts", handler("list"))
+	r.HandleWith("checkout <branch>", CommandOptions{
+		Desc:    "Check out a branch",
+		Aliases: []string{"co <branch>"},
+	}, handler("checkout"))
+	r.Handle("co <branch> sync", "Sync a checkout", handler("sync"))
+
+	for argv, want := range map[string]string{
+		"checkout main":      "checkout",
+		"co main":            "checkout",
+		"co main --force":    "checkout",
+		"co list":            "list",
+		"co main sync":       "sync",
+		"checkout main sync": "checkout",
+	} {
+		got = ""
+		if err := r.Run(context.Background(), strings.Fields(argv)); err != nil || got != want {
+			t.Errorf("Run(%q): got=%q err=%v, want %q", argv, got, err, want)
+		}
+	}
+
+	// The alias ranks like its canonical route would at the same position.
+	m := r.Matches(strings.Fields("co main"))
+	if len(m) != 1 || m[0].Pattern != "co <branch>" || !m[0].Hidden {
+		t.Fatalf("unexpected matches: %+v", m)
+	}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+}
+
+func TestRouter_Validate_ShadowedAlias(t *testing.T) {
+	r := New()
+	noop := func(*Request) error { return nil }
+	r.Handle("co <file>", "Check out a file", noop)
+	r.HandleWith("checkout <branch>", CommandOptions{
+		Desc:    "Check out a branch",
+		Aliases: []string{"co <branch>"},
+	}, noop)
+
+	err := r.Validate()
+	if err == nil || err.Error() != `route "co <branch>": alias of "checkout <branch>" is shadowed by "co <file>"` {
+		t.Fatalf("unexpected validation result: %v", err)
+	}
+}
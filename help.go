@@ -0,0 +1,366 @@
+package clir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// ErrShowHelp can be returned by a handler (or middleware or hook) to
+// abort and show the matched command's usage instead of an error. Run
+// prints the usage to Request.Stderr and returns an error that still
+// matches ErrShowHelp with errors.Is; HandleError prints nothing more for
+// it and returns ExitUsage. Wrap it to print a reason first:
+//
+//	return fmt.Errorf("missing --tag: %w", clir.ErrShowHelp)
+//	// Error: missing --tag: show help
+//	// Usage: mytool comp <component> image build
+//	// ...
+var ErrShowHelp = errors.New("show help")
+
+// helpShownError is what Run returns for an ErrShowHelp whose help it has
+// already printed. It prints nothing for HandleError and exits with
+// ExitUsage unless the wrapped error chooses a code.
+type helpShownError struct {
+	err error
+}
+
+func (e *helpShownError) Error() string { return e.err.Error() }
+
+func (e *helpShownError) Unwrap() error { return e.err }
+
+func (e *helpShownError) PrintError(io.Writer) {}
+
+func (e *helpShownError) ExitCode() int {
+	var coder ExitCoder
+	if errors.As(e.err, &coder) {
+		return coder.ExitCode()
+	}
+	return ExitUsage
+}
+
+// helpCommand is the literal that invokes the built-in help command.
+const helpCommand = "help"
+
+// EnableHelpCommand registers (or, with false, removes) a built-in
+// "help" command: "mytool help comp api image build" prints the details
+// of the route that would handle "comp api image build"; plain "help"
+// prints the full command list. When nothing matches, the commands that
+// start with the given tokens are suggested instead.
+func (r *Router) EnableHelpCommand(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("EnableHelpCommand")
+
+	routes := make([]route, 0, len(r.routes)+1)
+	for _, rt := range r.routes {
+		if !rt.builtin {
+			routes = append(routes, rt)
+		}
+	}
+	if enabled {
+		routes = append(routes, route{
+			parts:    []string{helpCommand},
+			segments: parseSegments([]string{helpCommand}),
+			handler:  r.serveHelp,
+			desc:     "Show help for a command",
+			builtin:  true,
+		})
+	}
+	r.routes = routes
+	r.trie.Store(nil)
+}
+
+// serveHelp is the handler of the built-in help command.
+func (r *Router) serveHelp(req *Request) error {
+	if len(req.Extra) == 0 {
+		r.printHelp(req.Stdout, req.noColor)
+		return nil
+	}
+
+	unlock := r.readLock()
+	defer unlock()
+
+	if rt, ok := r.lookup(req.Extra); ok && !rt.builtin {
+		if rt.mount != nil {
+			sub := *req
+			sub.Extra = req.Extra[len(rt.segments):]
+			sub.prog = joinProg(req.prog, rt.String())
+			return rt.mount.serveHelp(&sub)
+		}
+		printCommandHelp(req.Stdout, req.prog, rt, colorFor(r.color, req.noColor, req.Stdout))
+		return nil
+	}
+
+	suggestions := r.prefixEntries(req.Extra)
+	if len(suggestions) == 0 {
+		return fmt.Errorf("no help for unknown command `%s`", strings.Join(req.Extra, " "))
+	}
+	printMatching(req.Stdout, req.Extra, suggestions, r.listStyle(req.Stdout, req.noColor))
+	return nil
+}
+
+// EnableHelpFlag makes Run answer --help or -h anywhere before "--" with
+// help instead of running a command, even when the other tokens don't
+// form a complete command:
+//
+//   - a command matching the tokens exactly shows its help, as "help
+//     <command>" would
+//   - tokens that start longer commands, like "comp api image", list
+//     those commands
+//   - a command matching with arguments left over shows its help
+//   - otherwise the commands under the longest matching prefix are
+//     listed, or all commands if no prefix matches
+//
+// A rest segment such as <cmd...> that captures the flag keeps it, so
+// "exec ls --help" still runs "exec <cmd...>". With SetGlobalFlags,
+// --help and -h are also accepted among the global flags, unless the
+// program declares global flags of those names itself.
+//
+// Example:
+//
+//	r.EnableHelpFlag(true)
+//	// "mytool comp api image --help" lists "comp <component> image build"
+//	// and "comp <component> image push"
+func (r *Router) EnableHelpFlag(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("EnableHelpFlag")
+	r.helpFlag = enabled
+}
+
+// helpFlagTokens returns argv without its --help and -h tokens, the
+// index of the first one and whether there was any. It finds none unless
+// the help flag is enabled. Callers hold a read lock.
+func (r *Router) helpFlagTokens(argv []string) (rest []string, first int, found bool) {
+	first = -1
+	if !r.helpFlag || !slices.ContainsFunc(argv, func(tok string) bool { return tok == "--help" || tok == "-h" }) {
+		return nil, first, false
+	}
+	for i, tok := range argv {
+		if tok == "--help" || tok == "-h" {
+			if first < 0 {
+				first = i
+			}
+			continue
+		}
+		rest = append(rest, tok)
+	}
+	return rest, first, first >= 0
+}
+
+// helpFlagSpec returns the global flag spec for --help and -h, so global
+// flag parsing accepts them before the command when the help flag is
+// enabled. Names specs already declare are left out; ok is false if both
+// are taken or the help flag is disabled. Callers hold a read lock.
+func (r *Router) helpFlagSpec(specs []FlagSpec) (spec FlagSpec, ok bool) {
+	if !r.helpFlag {
+		return FlagSpec{}, false
+	}
+	spec = FlagSpec{Name: "help", Short: "h", Bool: true}
+	for _, s := range specs {
+		if s.Name == spec.Name || s.Short == spec.Name {
+			spec.Name = ""
+		}
+		if s.Name == spec.Short || s.Short == spec.Short {
+			spec.Short = ""
+		}
+	}
+	return spec, spec.Name != "" || spec.Short != ""
+}
+
+// capturesAt reports whether the matched route rt, if ok, captures the
+// token at index i in a rest segment.
+func capturesAt(rt *route, ok bool, i int) bool {
+	if !ok {
+		return false
+	}
+	_, rest := rt.rest()
+	return rest && i >= len(rt.segments)-1
+}
+
+// printFlagHelp prints the help EnableHelpFlag describes for tokens, with
+// usage lines prefixed by prog, and without color if noColor is set.
+func (r *Router) printFlagHelp(w io.Writer, prog string, tokens []string, noColor bool) {
+	unlock := r.readLock()
+	rt, ok := r.lookup(tokens)
+	if ok && rt.mount != nil {
+		child, sub := rt.mount, joinProg(prog, rt.String())
+		unlock()
+		child.printFlagHelp(w, sub, tokens[len(rt.segments):], noColor)
+		return
+	}
+	ok = ok && !rt.builtin
+
+	style := r.listStyle(w, noColor)
+	var entries []helpEntry
+	var prefix []string
+	switch {
+	case ok && rt.consumesAll(tokens):
+	case len(tokens) > 0 && len(r.prefixEntries(tokens)) > 0:
+		prefix, entries = tokens, r.prefixEntries(tokens)
+	case ok:
+	default:
+		for n := len(tokens) - 1; n > 0 && entries == nil; n-- {
+			prefix, entries = tokens[:n], r.prefixEntries(tokens[:n])
+		}
+	}
+	if entries == nil && ok {
+		printCommandHelp(w, prog, rt, style.color)
+		unlock()
+		return
+	}
+	unlock()
+
+	if len(entries) == 0 {
+		r.printHelp(w, noColor)
+		return
+	}
+	printMatching(w, prefix, entries, style)
+}
+
+// EnableGroupHelp turns automatic group help on or off. When enabled and
+// argv matches no route but is a prefix of some, like "remote" for
+// "remote add <name>" and "remote remove <name>", Run lists those
+// commands on stdout and returns nil instead of a no-match error. A route
+// registered for the prefix itself always takes precedence.
+func (r *Router) EnableGroupHelp(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("EnableGroupHelp")
+	r.groupHelp = enabled
+}
+
+// HelpOrder controls how command lists such as PrintHelp are sorted.
+type HelpOrder int
+
+const (
+	// HelpOrderSortHint sorts by literal segments, ordered first by their
+	// sort hints (the default): "1 comp" lists before "2 build".
+	HelpOrderSortHint HelpOrder = iota
+	// HelpOrderAlphabetical sorts by the rendered pattern, params
+	// included, ignoring sort hints.
+	HelpOrderAlphabetical
+	// HelpOrderRegistration keeps the order in which routes were
+	// registered, e.g. to list a workflow's steps in sequence.
+	HelpOrderRegistration
+)
+
+// SetHelpOrder sets how command lists such as PrintHelp are sorted.
+// Entries of equal rank keep their registration order.
+func (r *Router) SetHelpOrder(order HelpOrder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetHelpOrder")
+	r.helpOrder = order
+}
+
+// SetHelpMaxPatternWidth caps the width of the pattern column in command
+// lists such as PrintHelp. Patterns longer than n are printed on a line of
+// their own with the description on the next line, so a few long patterns
+// don't push every description to the right. Zero, the default, means no
+// cap.
+//
+// Example:
+//
+//	r.SetHelpMaxPatternWidth(30)
+//	// Available commands:
+//	//   comp <component> image build  Build images
+//	//   comp <component> image <image> tag <tag> push
+//	//                                  Push a tagged image
+func (r *Router) SetHelpMaxPatternWidth(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetHelpMaxPatternWidth")
+	r.helpWidth = n
+}
+
+// prefixEntries returns the help entries whose leading segments accept
+// tokens. Callers hold a read lock.
+func (r *Router) prefixEntries(tokens []string) []helpEntry {
+	var out []helpEntry
+	for _, e := range r.helpEntries() {
+		if hasSegmentPrefix(e.segs, tokens) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// printMatching prints the entries starting with tokens under a heading.
+func printMatching(w io.Writer, tokens []string, entries []helpEntry, style listStyle) {
+	fmt.Fprintf(w, "Commands matching `%s`:\n", strings.Join(tokens, " "))
+	printEntries(w, entries, style)
+}
+
+// hasSegmentPrefix reports whether tokens match the leading segments.
+func hasSegmentPrefix(segs []segment, tokens []string) bool {
+	if len(tokens) > len(segs) {
+		return false
+	}
+	for i, tok := range tokens {
+		if !segs[i].matches(tok) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetName sets the program name shown in usage lines, e.g.
+// "Usage: mytool comp <component> image build". It defaults to the base
+// name of os.Args[0], if any; an empty name leaves it out. Mounted routers use
+// the parent's name followed by the mount point instead of their own.
+func (r *Router) SetName(prog string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetName")
+	r.name = prog
+}
+
+// Name returns the program name set with SetName.
+func (r *Router) Name() string {
+	unlock := r.readLock()
+	defer unlock()
+	return r.name
+}
+
+// joinProg appends words to a program name, either of which may be empty.
+func joinProg(prog, words string) string {
+	if prog == "" {
+		return words
+	}
+	return prog + " " + words
+}
+
+// printCommandHelp prints the details of a single route, with its usage
+// line prefixed by prog and followed by the declared flags. The long
+// description replaces the one-line one when set, and the route's
+// deprecation, params, declared flags, examples and timeout follow.
+func printCommandHelp(w io.Writer, prog string, rt *route, color bool) {
+	usage := joinProg(prog, rt.String())
+	if len(rt.flags) > 0 {
+		usage += " " + flagSynopsis(rt.flags)
+	}
+	fmt.Fprintf(w, "%s %s\n", bold("Usage:", color), usage)
+	if len(rt.aliases) > 0 {
+		fmt.Fprintf(w, "%s %s\n", bold("Aliases:", color), strings.Join(rt.aliases, ", "))
+	}
+	text := rt.long
+	if text == "" {
+		text = rt.desc
+	}
+	if text != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.TrimSuffix(text, "\n"))
+	}
+	if rt.deprecated != "" {
+		fmt.Fprintf(w, "\n%s %s\n", bold("Deprecated:", color), rt.deprecated)
+	}
+	printArguments(w, rt, color)
+	printFlags(w, rt, color)
+	printExamples(w, rt, color)
+	if rt.timeout > 0 {
+		fmt.Fprintf(w, "\n%s %s (override with --timeout)\n", bold("Timeout:", color), rt.timeout)
+	}
+}
@@ -0,0 +1,429 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newHelpRouter() (*Router, *bytes.Buffer) {
+	r := New()
+	r.SetName("mytool")
+	noop := func(*Request) error { return nil }
+	r.Handle("comp <component> image build", "Build images", noop)
+	r.Handle("comp <component> image push", "Push images", noop)
+	r.Handle("version", "Show version", noop)
+
+	var out bytes.Buffer
+	r.SetOutput(&out, &out)
+	return r, &out
+}
+
+func TestRouter_EnableHelpCommand(t *testing.T) {
+	r, out := newHelpRouter()
+	r.EnableHelpCommand(true)
+
+	if err := r.Run(context.Background(), []string{"help", "comp", "api", "image", "build"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := out.String(); got != "Usage: mytool comp <component> image build\n\nBuild images\n\nArguments:\n  <component>\n" {
+		t.Fatalf("unexpected command help: %q", got)
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"help"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "Available commands:") || !strings.Contains(got, "help") {
+		t.Fatalf("unexpected full help: %q", got)
+	}
+}
+
+func TestRouter_EnableHelpCommand_Suggestions(t *testing.T) {
+	r, out := newHelpRouter()
+	r.EnableHelpCommand(true)
+
+	if err := r.Run(context.Background(), []string{"help", "comp", "api"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	got := out.String()
+	if !strings.HasPrefix(got, "Commands matching `comp api`:") ||
+		!strings.Contains(got, "comp <component> image build") ||
+		!strings.Contains(got, "comp <component> image push") ||
+		strings.Contains(got, "version") {
+		t.Fatalf("unexpected suggestions: %q", got)
+	}
+
+	err := r.Run(context.Background(), []string{"help", "nope"})
+	if err == nil || !strings.Contains(err.Error(), "no help for unknown command `nope`") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRouter_EnableHelpCommand_Disable(t *testing.T) {
+	r, _ := newHelpRouter()
+	r.EnableHelpCommand(true)
+	r.EnableHelpCommand(true)
+	if len(r.routes) != 4 {
+		t.Fatalf("help command registered more than once: %d routes", len(r.routes))
+	}
+
+	r.EnableHelpCommand(false)
+	err := r.Run(context.Background(), []string{"help"})
+	if err == nil || !strings.Contains(err.Error(), "no matching command") {
+		t.Fatalf("expected help to be gone, got %v", err)
+	}
+}
+
+func TestRouter_EnableHelpCommand_Mount(t *testing.T) {
+	child := New()
+	child.Handle("install <name>", "Install a plugin", func(*Request) error { return nil })
+
+	r, out := newHelpRouter()
+	r.Mount("plugin", child)
+	r.EnableHelpCommand(true)
+
+	if err := r.Run(context.Background(), []string{"help", "plugin", "install", "lint"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "Usage: mytool plugin install <name>") {
+		t.Fatalf("unexpected mounted command help: %q", got)
+	}
+}
+
+func TestRouter_ErrShowHelp(t *testing.T) {
+	r, out := newHelpRouter()
+	r.Handle("deploy <env>", "Deploy to an environment", func(req *Request) error {
+		if _, ok := req.Flag("tag"); !ok {
+			return fmt.Errorf("missing --tag: %w", ErrShowHelp)
+		}
+		return ErrShowHelp
+	})
+
+	if err := r.Run(context.Background(), []string{"deploy", "prod"}); !errors.Is(err, ErrShowHelp) {
+		t.Fatalf("Run returned %v, want ErrShowHelp", err)
+	}
+	want := "Error: missing --tag: show help\nUsage: mytool deploy <env>\n\nDeploy to an environment\n\nArguments:\n  <env>\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%q\nwant:\n%q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"deploy", "prod", "--tag", "v1"}); !errors.Is(err, ErrShowHelp) {
+		t.Fatalf("Run returned %v, want ErrShowHelp", err)
+	}
+	if !strings.HasPrefix(out.String(), "Usage: mytool deploy <env>") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestRouter_SetName(t *testing.T) {
+	if got, want := New().Name(), filepath.Base(os.Args[0]); got != want {
+		t.Fatalf("default Name() = %q, want %q", got, want)
+	}
+
+	child := New()
+	child.SetName("ignored")
+	child.Handle("install <name>", "Install a plugin", func(*Request) error { return ErrShowHelp })
+
+	r, out := newHelpRouter()
+	r.Mount("plugin", child)
+
+	if err := r.Run(context.Background(), []string{"plugin", "install", "lint"}); !errors.Is(err, ErrShowHelp) {
+		t.Fatalf("Run returned %v, want ErrShowHelp", err)
+	}
+	if got := out.String(); !strings.HasPrefix(got, "Usage: mytool plugin install <name>\n") {
+		t.Fatalf("unexpected mounted usage: %q", got)
+	}
+
+	r.SetName("")
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"plugin", "install", "lint"}); !errors.Is(err, ErrShowHelp) {
+		t.Fatalf("Run returned %v, want ErrShowHelp", err)
+	}
+	if got := out.String(); !strings.HasPrefix(got, "Usage: plugin install <name>\n") {
+		t.Fatalf("unexpected usage without a name: %q", got)
+	}
+}
+
+func TestNew_WithoutArgs(t *testing.T) {
+	args := os.Args
+	defer func() { os.Args = args }()
+	os.Args = nil
+
+	if name := New().Name(); name != "" {
+		t.Fatalf("Name() = %q, want empty", name)
+	}
+}
+
+func TestBuilder_Long(t *testing.T) {
+	r, out := newHelpRouter()
+	r.EnableHelpCommand(true)
+	r.Routes(func(b *Builder) {
+		long := "Deploy the current build.\n\n  Rollbacks use the previous tag.\n"
+		b.Long(long).Timeout(time.Minute).Handle("deploy <env>", "Deploy to an environment", func(*Request) error { return nil })
+		b.Handle("rollback <env>", "Roll back an environment", func(*Request) error { return nil })
+	})
+
+	if err := r.Run(context.Background(), []string{"help", "deploy", "prod"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := "Usage: mytool deploy <env>\n\nDeploy the current build.\n\n  Rollbacks use the previous tag.\n\nArguments:\n  <env>\n\nTimeout: 1m0s (override with --timeout)\n"
+	if out.String() != want {
+		t.Fatalf("unexpected command help:\n%q\nwant:\n%q", out.String(), want)
+	}
+
+	out.Reset()
+	r.PrintHelp(out)
+	if got := out.String(); !strings.Contains(got, "Deploy to an environment") || strings.Contains(got, "Rollbacks") {
+		t.Fatalf("list should show only the summary: %q", got)
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"help", "rollback", "prod"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "\n\nRoll back an environment\n") {
+		t.Fatalf("Long should not leak to sibling routes: %q", got)
+	}
+}
+
+func TestBuilder_Param(t *testing.T) {
+	r, out := newHelpRouter()
+	r.EnableHelpCommand(true)
+	noop := func(*Request) error { return nil }
+	r.Routes(func(b *Builder) {
+		b.Param("ws", WithDescription("workspace name")).Route("ws <ws>", func(b *Builder) {
+			b.Param("cmd", WithDescription("command to run")).Handle("exec <task> <cmd...>", "Run in a workspace", noop)
+			b.Handle("show", "Show a workspace", noop)
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"help", "ws", "dev", "exec", "t1", "ls"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := `Usage: mytool ws <ws> exec <task> <cmd...>
+
+Run in a workspace
+
+Arguments:
+  <ws>      workspace name
+  <task>
+  <cmd...>  command to run
+`
+	if out.String() != want {
+		t.Fatalf("unexpected command help:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"help", "ws", "dev", "show"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := out.String(); !strings.HasSuffix(got, "Arguments:\n  <ws>  workspace name\n") {
+		t.Fatalf("nested routes should inherit param descriptions: %q", got)
+	}
+}
+
+func TestCommandHelp_Timeout(t *testing.T) {
+	r, out := newHelpRouter()
+	r.EnableHelpCommand(true)
+	r.Routes(func(b *Builder) {
+		b.Timeout(30*time.Second).Handle("sync", "Sync remote state", func(*Request) error { return nil })
+	})
+
+	if err := r.Run(context.Background(), []string{"help", "sync"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := "Usage: mytool sync\n\nSync remote state\n\nTimeout: 30s (override with --timeout)\n"
+	if out.String() != want {
+		t.Fatalf("unexpected command help:\n%q\nwant:\n%q", out.String(), want)
+	}
+
+	for _, c := range r.Commands() {
+		if want := map[string]time.Duration{"sync": 30 * time.Second}[c.Pattern]; c.Timeout != want {
+			t.Errorf("Commands(): %q has timeout %v, want %v", c.Pattern, c.Timeout, want)
+		}
+	}
+}
+
+func TestRouter_EnableGroupHelp(t *testing.T) {
+	r, out := newHelpRouter()
+	noop := func(*Request) error { return nil }
+	r.Handle("remote add <name>", "Add a remote", noop)
+	r.Handle("remote remove <name>", "Remove a remote", noop)
+	r.Handle("stash", "Stash changes", noop)
+	r.Handle("stash pop", "Pop the stash", noop)
+
+	if err := r.Run(context.Background(), []string{"remote"}); err == nil {
+		t.Fatal("expected no match without group help")
+	}
+
+	r.EnableGroupHelp(true)
+	if err := r.Run(context.Background(), []string{"remote"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := "Commands matching `remote`:\n  remote add <name>     Add a remote\n  remote remove <name>  Remove a remote\n"
+	if out.String() != want {
+		t.Fatalf("unexpected group help:\n%q\nwant:\n%q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"comp", "api"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := out.String(); !strings.Contains(got, "comp <component> image build") || !strings.Contains(got, "comp <component> image push") {
+		t.Fatalf("unexpected group help for a param prefix: %q", got)
+	}
+
+	// An explicit route for the prefix wins, and non-prefixes still fail.
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"stash"}); err != nil || out.Len() != 0 {
+		t.Fatalf("explicit route should run: err=%v output=%q", err, out.String())
+	}
+	if err := r.Run(context.Background(), []string{"remote", "rename"}); err == nil {
+		t.Fatal("expected no match for a non-prefix")
+	}
+}
+
+func TestRouter_SetHelpMaxPatternWidth(t *testing.T) {
+	r, out := newHelpRouter()
+	r.Handle("comp <component> image <image> tag <tag> push", "Push a tagged image", func(*Request) error { return nil })
+	r.SetHelpMaxPatternWidth(30)
+
+	r.PrintHelp(out)
+	want := "Available commands:\n" +
+		"  comp <component> image build  Build images\n" +
+		"  comp <component> image push   Push images\n" +
+		"  comp <component> image <image> tag <tag> push\n" +
+		"                                Push a tagged image\n" +
+		"  version                       Show version\n"
+	if out.String() != want {
+		t.Fatalf("unexpected help:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	r.SetHelpMaxPatternWidth(0)
+	r.PrintHelp(out)
+	if !strings.Contains(out.String(), "  version                                        Show version\n") {
+		t.Fatalf("expected no cap by default:\n%s", out.String())
+	}
+}
+
+func TestRouter_SetHelpOrder(t *testing.T) {
+	r := New()
+	noop := func(*Request) error { return nil }
+	r.Handle("2 deploy", "Deploy", noop)
+	r.Handle("1 build <target>", "Build", noop)
+	r.Handle("1 build all", "Build-all", noop)
+	r.Handle("3 archive", "Archive", noop)
+
+	tests := []struct {
+		order HelpOrder
+		want  []string
+	}{
+		{HelpOrderSortHint, []string{"build <target>", "build all", "deploy", "archive"}},
+		{HelpOrderAlphabetical, []string{"archive", "build <target>", "build all", "deploy"}},
+		{HelpOrderRegistration, []string{"deploy", "build <target>", "build all", "archive"}},
+	}
+	for _, tt := range tests {
+		r.SetHelpOrder(tt.order)
+		var out bytes.Buffer
+		r.PrintHelp(&out)
+
+		// Descriptions are one word, so the pattern is all but the last field.
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n")[1:] {
+			fields := strings.Fields(line)
+			got = append(got, strings.Join(fields[:len(fields)-1], " "))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("order %d: got %q, want %q", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_EnableHelpFlag(t *testing.T) {
+	r, out := newHelpRouter()
+	ran := ""
+	r.Handle("exec <cmd...>", "Run a program", func(req *Request) error {
+		ran = strings.Join(req.ParamLists["cmd"], " ")
+		return nil
+	})
+	plugins := New()
+	plugins.Handle("install <name>", "Install a plugin", func(*Request) error { return nil })
+	r.Mount("plugin", plugins)
+	r.EnableHelpFlag(true)
+
+	build := "Usage: mytool comp <component> image build\n\nBuild images\n\nArguments:\n  <component>\n"
+	image := "Commands matching `comp api image`:\n" +
+		"  comp <component> image build  Build images\n" +
+		"  comp <component> image push   Push images\n"
+	tests := []struct {
+		argv string
+		want string
+	}{
+		{"comp api image build --help", build},
+		{"comp api image build --tag v1 -h", build},
+		{"comp api image --help", image},
+		{"comp --help api image", image},
+		{"comp api image bogus --help", image},
+		{"plugin install lint --help", "Usage: mytool plugin install <name>\n\nInstall a plugin\n\nArguments:\n  <name>\n"},
+		{"comp api image build -- --help", ""},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		err := r.Run(context.Background(), strings.Fields(tt.argv))
+		if err != nil || out.String() != tt.want {
+			t.Errorf("Run(%q): err=%v, output:\n%s\nwant:\n%s", tt.argv, err, out.String(), tt.want)
+		}
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"bogus", "--help"}); err != nil || !strings.HasPrefix(out.String(), "Available commands:\n") {
+		t.Errorf("expected the full command list, err=%v, output:\n%s", err, out.String())
+	}
+
+	out.Reset()
+	if err := r.Run(context.Background(), []string{"exec", "ls", "--help"}); err != nil || ran != "ls --help" || out.Len() != 0 {
+		t.Errorf("expected the rest segment to keep --help: ran=%q err=%v output=%q", ran, err, out.String())
+	}
+}
+
+func TestRouter_EnableHelpFlag_WithGlobalFlags(t *testing.T) {
+	r, out := newHelpRouter()
+	r.SetGlobalFlags(FlagSpec{Name: "verbose", Short: "v", Bool: true})
+	r.EnableHelpFlag(true)
+
+	image := "Commands matching `comp api image`:\n" +
+		"  comp <component> image build  Build images\n" +
+		"  comp <component> image push   Push images\n"
+	tests := []struct {
+		argv string
+		want string
+	}{
+		{"--help", "Available commands:\n"},
+		{"-h comp api image", image},
+		{"-v --help comp api image", image},
+		{"-v comp api image --help", image},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		err := r.Run(context.Background(), strings.Fields(tt.argv))
+		if err != nil || !strings.HasPrefix(out.String(), tt.want) {
+			t.Errorf("Run(%q): err=%v, output:\n%s\nwant prefix:\n%s", tt.argv, err, out.String(), tt.want)
+		}
+	}
+
+	// A global flag of the program's own keeps its meaning.
+	r.SetGlobalFlags(FlagSpec{Name: "host", Short: "h"})
+	var noMatch *NoMatchError
+	if err := r.Run(context.Background(), []string{"-h", "example.com", "bogus"}); !errors.As(err, &noMatch) {
+		t.Errorf("-h declared as a global flag: err=%v, want no match", err)
+	}
+}
@@ -0,0 +1,88 @@
+package clir
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"time"
+)
+
+// Invoke runs the handler of the route registered with pattern, bypassing
+// matching, so tests can exercise a command with crafted params. Pattern
+// is compared as rendered by Commands (sort hints are ignored), and a
+// pattern not registered is an error.
+//
+// The Request carries params and extra as given; for a route ending in a
+// list or rest segment, extra fills that param's ParamLists entry first,
+// as Run would. Request.Args is nil. Everything after matching applies as
+// in Run: middleware, Before/After hooks, the route's timeout, strict
+// checks and the deprecation warning.
+//
+// Example:
+//
+//	err := r.Invoke(ctx, "comp <component> image build",
+//	    clir.Params{"component": "api"}, []string{"--tag", "v1"})
+func (r *Router) Invoke(ctx context.Context, pattern string, params Params, extra []string) error {
+	want := (&route{segments: parseSegments(splitPattern(pattern))}).String()
+
+	unlock := r.readLock()
+	var rt *route
+	for i := range r.routes {
+		if r.routes[i].String() == want {
+			rt = &r.routes[i]
+			break
+		}
+	}
+	if rt == nil {
+		unlock()
+		return fmt.Errorf("no route with pattern `%s`", want)
+	}
+	before, after := r.before, r.after
+	stdout, stderr := r.stdout, r.stderr
+	dryRun := r.dryRun
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.baseCtx != nil {
+		ctx = layeredContext{ctx, r.baseCtx}
+	}
+	ctx = withSession(ctx, r.session)
+	req := &Request{
+		ctx:       ctx,
+		route:     rt,
+		router:    r,
+		memo:      &resolverMemo{},
+		prog:      r.name,
+		Params:    maps.Clone(params),
+		Extra:     extra,
+		Stdout:    os.Stdout,
+		Stderr:    os.Stderr,
+		StartedAt: time.Now(),
+	}
+	unlock()
+
+	if req.Params == nil {
+		req.Params = Params{}
+	}
+	if n := len(rt.segments); n > 0 {
+		if s := rt.segments[n-1]; s.last() {
+			end := len(extra)
+			if s.list {
+				end = 0
+				for end < len(extra) && s.matches(extra[end]) {
+					end++
+				}
+			}
+			req.ParamLists = map[string][]string{s.param: extra[:end]}
+			req.Extra = extra[end:]
+		}
+	}
+	if stdout != nil {
+		req.Stdout = stdout
+	}
+	if stderr != nil {
+		req.Stderr = stderr
+	}
+	return r.execute(rt, req, before, after, dryRun)
+}
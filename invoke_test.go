@@ -0,0 +1,85 @@
+package clir
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestRouter_Invoke(t *testing.T) {
+	r := New()
+	var got *Request
+	var steps []string
+	r.Before(func(req *Request) error {
+		steps = append(steps, "before")
+		return nil
+	})
+	h := func(req *Request) error {
+		got = req
+		steps = append(steps, "handler")
+		return nil
+	}
+	r.Handle("1 comp <component> 2 image build", "Build images", h)
+	r.Handle("tag add <tags+>", "Add tags", h)
+
+	params := Params{"component": "api"}
+	if err := r.Invoke(context.Background(), "comp <component> image build", params, []string{"--tag", "v1"}); err != nil {
+		t.Fatalf("Invoke returned error: %v", err)
+	}
+	if got.Params["component"] != "api" || fmt.Sprint(got.Extra) != "[--tag v1]" || got.Pattern() != "comp <component> image build" {
+		t.Fatalf("unexpected request: params=%v extra=%v pattern=%q", got.Params, got.Extra, got.Pattern())
+	}
+	if v, _ := got.Flag("tag"); v != "v1" {
+		t.Fatalf("unexpected --tag: %q", v)
+	}
+	got.Params["component"] = "changed"
+	if params["component"] != "api" {
+		t.Fatal("Invoke should not share the caller's params map")
+	}
+	if fmt.Sprint(steps) != "[before handler]" {
+		t.Fatalf("unexpected steps: %v", steps)
+	}
+
+	if err := r.Invoke(context.Background(), "tag add <tags+>", nil, []string{"a", "b", "--push"}); err != nil {
+		t.Fatalf("Invoke returned error: %v", err)
+	}
+	if fmt.Sprint(got.ParamLists["tags"]) != "[a b]" || fmt.Sprint(got.Extra) != "[--push]" {
+		t.Fatalf("unexpected list capture: lists=%v extra=%v", got.ParamLists, got.Extra)
+	}
+
+	err := r.Invoke(context.Background(), "comp <component> image push", nil, nil)
+	if err == nil || err.Error() != "no route with pattern `comp <component> image push`" {
+		t.Fatalf("unexpected error for unknown pattern: %v", err)
+	}
+}
+
+func TestRouter_Invoke_CommandsRoundTrip(t *testing.T) {
+	// Each pattern is written as String renders it, so Commands reports it
+	// unchanged and Invoke finds the same route again.
+	patterns := []string{`ipv \4`, `list \*`, `list *`, `show \<id>`, `show <id>`, `"add user"`}
+	r := New()
+	var ran string
+	for _, pattern := range patterns {
+		r.Handle(pattern, "desc", func(*Request) error {
+			ran = pattern
+			return nil
+		})
+	}
+
+	var got []string
+	for _, c := range r.Commands() {
+		got = append(got, c.Pattern)
+		ran = ""
+		if err := r.Invoke(context.Background(), c.Pattern, Params{"id": "1"}, nil); err != nil {
+			t.Errorf("Invoke(%q) returned error: %v", c.Pattern, err)
+		} else if ran != c.Pattern {
+			t.Errorf("Invoke(%q) ran %q", c.Pattern, ran)
+		}
+	}
+	slices.Sort(got)
+	slices.Sort(patterns)
+	if !slices.Equal(got, patterns) {
+		t.Fatalf("Commands() patterns = %q, want %q", got, patterns)
+	}
+}
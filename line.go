@@ -0,0 +1,162 @@
+package clir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SplitLine splits a command line into argv the way a POSIX shell would,
+// without expansions:
+//
+//   - unquoted whitespace separates arguments
+//   - '...' is taken literally
+//   - "..." is taken literally except for \" and \\
+//   - outside quotes, a backslash escapes the next character
+//
+// Quotes may be adjacent to other text (--msg="hi there" is one argument)
+// and "" yields an empty argument.
+func SplitLine(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+
+		case '\\':
+			if i+1 >= len(line) {
+				return nil, errors.New("trailing backslash in command line")
+			}
+			i++
+			cur.WriteByte(line[i])
+			inArg = true
+
+		case '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote in command line")
+			}
+			cur.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+
+		case '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\') {
+					i++
+				}
+				cur.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return nil, errors.New("unterminated double quote in command line")
+			}
+			inArg = true
+
+		default:
+			cur.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// Tokenizer splits a command line into argv, see SetTokenizer.
+type Tokenizer func(line string) ([]string, error)
+
+// SetTokenizer sets how the line-based entry points (RunLine, RunAll and
+// REPL) split a line into argv. A nil tokenizer restores the default,
+// SplitLine.
+//
+// Example (comma-separated input):
+//
+//	r.SetTokenizer(func(line string) ([]string, error) {
+//	    return strings.Split(line, ","), nil
+//	})
+func (r *Router) SetTokenizer(fn Tokenizer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetTokenizer")
+	r.tokenizer = fn
+}
+
+// splitLine splits line with the router's tokenizer.
+func (r *Router) splitLine(line string) ([]string, error) {
+	unlock := r.readLock()
+	fn := r.tokenizer
+	unlock()
+	if fn == nil {
+		fn = SplitLine
+	}
+	return fn(line)
+}
+
+// RunLine splits line into argv and runs it. Lines are split with
+// SplitLine unless another tokenizer is set (see SetTokenizer).
+//
+// Example:
+//
+//	err := r.RunLine(ctx, `comp api deploy --msg "release 1.2"`)
+func (r *Router) RunLine(ctx context.Context, line string) error {
+	argv, err := r.splitLine(line)
+	if err != nil {
+		return err
+	}
+	return r.Run(ctx, argv)
+}
+
+// RunAllOptions configures Router.RunAll.
+type RunAllOptions struct {
+	// StopOnError stops at the first failing line instead of running the
+	// rest and reporting all errors.
+	StopOnError bool
+}
+
+// RunAll runs each line with RunLine, in order, like a script. Blank
+// lines and lines starting with '#' are skipped. Errors are prefixed with
+// their 1-based line number and joined with errors.Join. With
+// StopOnError, RunAll returns after the first error; it always stops once
+// ctx is done.
+//
+// Example:
+//
+//	err := r.RunAll(ctx, []string{
+//	    "# migrate",
+//	    "db migrate --to 42",
+//	    "cache flush",
+//	}, clir.RunAllOptions{StopOnError: true})
+func (r *Router) RunAll(ctx context.Context, lines []string, opts RunAllOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var errs []error
+	for i, line := range lines {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if err := r.RunLine(ctx, line); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", i+1, err))
+			if opts.StopOnError {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,139 @@
+package clir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  comp   api\tbuild ", []string{"comp", "api", "build"}},
+		{`say "hello world"`, []string{"say", "hello world"}},
+		{`say 'it''s' "a \"quote\" \\ \n"`, []string{"say", "its", `a "quote" \ \n`}},
+		{`--msg="hi there" x`, []string{"--msg=hi there", "x"}},
+		{`path\ with\ spaces \'`, []string{"path with spaces", "'"}},
+		{`empty "" ''`, []string{"empty", "", ""}},
+	}
+
+	for _, tt := range tests {
+		got, err := SplitLine(tt.line)
+		if err != nil {
+			t.Errorf("SplitLine(%q) returned error: %v", tt.line, err)
+			continue
+		}
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("SplitLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`say "oops`, `say 'oops`, `say oops\`} {
+		if _, err := SplitLine(bad); err == nil {
+			t.Errorf("SplitLine(%q) should fail", bad)
+		}
+	}
+}
+
+func TestRouter_RunLine(t *testing.T) {
+	r := New()
+
+	var got *Request
+	r.Handle("comp <component> deploy", "Deploy", func(req *Request) error {
+		got = req
+		return nil
+	})
+
+	if err := r.RunLine(context.Background(), `comp 'my api' deploy --msg "release 1.2"`); err != nil {
+		t.Fatalf("RunLine returned error: %v", err)
+	}
+	if got.Params["component"] != "my api" || fmt.Sprintf("%q", got.Extra) != `["--msg" "release 1.2"]` {
+		t.Fatalf("unexpected request: params=%v extra=%q", got.Params, got.Extra)
+	}
+
+	if err := r.RunLine(context.Background(), `comp "api`); err == nil {
+		t.Fatal("expected tokenizer error")
+	}
+}
+
+func TestRouter_RunAll(t *testing.T) {
+	r := New()
+	var ran []string
+	r.Handle("step <n>", "Run a step", func(req *Request) error {
+		ran = append(ran, req.Params["n"])
+		if req.Params["n"] == "fail" {
+			return errors.New("step failed")
+		}
+		return nil
+	})
+
+	lines := []string{
+		"# setup",
+		"step 1",
+		"",
+		"step fail",
+		"nope",
+		`step "3"`,
+	}
+
+	err := r.RunAll(context.Background(), lines, RunAllOptions{})
+	want := "line 4: step failed\nline 5: no matching command for `nope`"
+	if err == nil || err.Error() != want {
+		t.Fatalf("RunAll() = %v, want %q", err, want)
+	}
+	if fmt.Sprint(ran) != "[1 fail 3]" {
+		t.Fatalf("unexpected steps: %v", ran)
+	}
+
+	ran = nil
+	err = r.RunAll(context.Background(), lines, RunAllOptions{StopOnError: true})
+	if err == nil || err.Error() != "line 4: step failed" {
+		t.Fatalf("RunAll(StopOnError) = %v", err)
+	}
+	if fmt.Sprint(ran) != "[1 fail]" {
+		t.Fatalf("unexpected steps: %v", ran)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran = nil
+	if err := r.RunAll(ctx, lines, RunAllOptions{}); !errors.Is(err, context.Canceled) || ran != nil {
+		t.Fatalf("RunAll(canceled) = %v, ran %v", err, ran)
+	}
+}
+
+func TestRouter_SetTokenizer(t *testing.T) {
+	r := New()
+	var got []string
+	r.Handle("say <word>", "Say a word", func(req *Request) error {
+		got = append(got, req.Params["word"])
+		return nil
+	})
+
+	r.SetTokenizer(func(line string) ([]string, error) {
+		if line == "bad" {
+			return nil, errors.New("cannot tokenize")
+		}
+		return strings.Split(line, ","), nil
+	})
+
+	if err := r.RunLine(context.Background(), "say,hello world"); err != nil {
+		t.Fatalf("RunLine returned error: %v", err)
+	}
+	if err := r.RunAll(context.Background(), []string{"say,a b", "bad"}, RunAllOptions{}); err == nil || err.Error() != "line 2: cannot tokenize" {
+		t.Fatalf("RunAll returned %v", err)
+	}
+
+	r.SetTokenizer(nil)
+	if err := r.RunLine(context.Background(), `say "x y"`); err != nil {
+		t.Fatalf("RunLine returned error: %v", err)
+	}
+	if fmt.Sprintf("%q", got) != `["hello world" "a b" "x y"]` {
+		t.Fatalf("unexpected words: %q", got)
+	}
+}
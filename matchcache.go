@@ -0,0 +1,88 @@
+package clir
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+)
+
+// EnableMatchCache caches the route selected for each argv, keeping the
+// size most recently used entries, for hot dispatch loops such as a REPL
+// or a test running the same lines many times. Entries are keyed on the
+// exact argv, since globs and key=value segments make route selection
+// depend on token values; params are still captured per Run. Registering
+// a route clears the cache. A size of 0 or less disables it.
+func (r *Router) EnableMatchCache(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("EnableMatchCache")
+	if size <= 0 {
+		r.cache = nil
+		return
+	}
+	r.cache = &matchCache{size: size}
+}
+
+// matchCache is an LRU cache from argv to route index (-1 for no match).
+// Entries belong to one match index and are dropped when it changes.
+type matchCache struct {
+	mu    sync.Mutex
+	size  int
+	trie  *node
+	order *list.List // of *cacheEntry, most recently used first
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key string
+	idx int
+}
+
+// get returns the cached route index for argv under trie t, calling
+// match and caching its result on a miss.
+func (c *matchCache) get(t *node, argv []string, match func() int) int {
+	key := cacheKey(argv)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.trie != t {
+		c.trie = t
+		c.order = list.New()
+		c.items = map[string]*list.Element{}
+	}
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*cacheEntry).idx
+	}
+
+	idx := match()
+	c.items[key] = c.order.PushFront(&cacheEntry{key, idx})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+	return idx
+}
+
+// len returns the number of cached entries.
+func (c *matchCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.order == nil {
+		return 0
+	}
+	return c.order.Len()
+}
+
+// cacheKey encodes argv unambiguously as length-prefixed tokens.
+func cacheKey(argv []string) string {
+	var b []byte
+	for _, tok := range argv {
+		b = strconv.AppendInt(b, int64(len(tok)), 10)
+		b = append(b, ':')
+		b = append(b, tok...)
+	}
+	return string(b)
+}
@@ -0,0 +1,63 @@
+package clir
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRouter_EnableMatchCache(t *testing.T) {
+	r := New()
+	var got string
+	h := func(name string) Handler {
+		return func(req *Request) error {
+			got = name + " " + strings.Join(req.Extra, " ")
+			return nil
+		}
+	}
+	r.Handle("users <id>", "Show user", h("user"))
+	r.Handle("log v*", "Show log", h("log"))
+	r.EnableMatchCache(2)
+
+	run := func(line, want string) {
+		t.Helper()
+		got = ""
+		err := r.Run(context.Background(), strings.Fields(line))
+		if want == "" {
+			if err == nil {
+				t.Fatalf("Run(%q) should not match", line)
+			}
+			return
+		}
+		if err != nil || got != want {
+			t.Fatalf("Run(%q) = %v, ran %q; want %q", line, err, got, want)
+		}
+	}
+
+	run("users 1 --a", "user --a")
+	run("users 1 --a", "user --a") // hit
+	run("log v1", "log ")
+	run("log x1", "") // token values matter, not just the shape
+	if n := r.cache.len(); n != 2 {
+		t.Fatalf("cache holds %d entries, want 2 (LRU evicted)", n)
+	}
+
+	// Registering a route invalidates cached selections.
+	r.Handle("users me", "Show me", h("me"))
+	run("users me", "me ")
+	if n := r.cache.len(); n != 1 {
+		t.Fatalf("cache holds %d entries after Handle, want 1", n)
+	}
+
+	r.EnableMatchCache(0)
+	if r.cache != nil {
+		t.Fatal("EnableMatchCache(0) should disable the cache")
+	}
+	run("users 2", "user ")
+}
+
+func TestCacheKey_Unambiguous(t *testing.T) {
+	if cacheKey([]string{"a b"}) == cacheKey([]string{"a", "b"}) || cacheKey([]string{"ab"}) == cacheKey([]string{"a", "b"}) {
+		t.Fatal("cache keys collide")
+	}
+}
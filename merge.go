@@ -0,0 +1,110 @@
+package clir
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Merge copies other's routes into r, under prefix if it isn't empty, so
+// command sets built as separate routers (e.g. in different packages)
+// can be combined into one. Each route keeps its handler as registered,
+// including any middleware, timeout and metadata applied there; other's
+// Before, After and PreRun hooks and its settings are not copied. The
+// built-in help command is left out, and mounted routers stay shared.
+//
+// If a route of other would match the same argv with the same rank as a
+// route already in r, Merge returns an error naming every such conflict
+// and registers nothing.
+//
+// Example:
+//
+//	plugins := clir.New()
+//	plugins.Handle("list", "List plugins", listPlugins)
+//
+//	if err := r.Merge(plugins, "plugin"); err != nil {
+//	    log.Fatal(err)
+//	}
+//	// "plugin list" runs listPlugins
+func (r *Router) Merge(other *Router, prefix string) error {
+	if other == r {
+		return errors.New("cannot merge a router into itself")
+	}
+	pre := splitPattern(prefix)
+	if err := checkParts(pre); err != nil {
+		return fmt.Errorf("invalid prefix %q: %w", prefix, err)
+	}
+	preSegs := parseSegments(pre)
+	preStr := (&route{segments: preSegs}).String()
+
+	unlock := other.readLock()
+	var routes []route
+	for i := range other.routes {
+		if other.routes[i].builtin {
+			continue
+		}
+		rt := other.routes[i].clone()
+		if len(pre) > 0 {
+			rt.parts = append(slices.Clip(pre), rt.parts...)
+			// Keep the segments as registered: reparsing would drop
+			// what Builder.Param set, such as Required and NoFlags.
+			rt.segments = append(slices.Clip(preSegs), rt.segments...)
+			for j, a := range rt.aliases {
+				rt.aliases[j] = preStr + " " + a
+			}
+			if rt.aliasOf != "" {
+				rt.aliasOf = preStr + " " + rt.aliasOf
+			}
+		}
+		routes = append(routes, rt)
+	}
+	unlock()
+
+	for i := range routes {
+		if name, ok := routes[i].duplicateParam(); ok {
+			return fmt.Errorf("duplicate param <%s> in pattern %q", name, routes[i].String())
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("Merge")
+
+	shapes := map[string]*route{}
+	for i := range r.routes {
+		if s := r.routes[i].shape(); shapes[s] == nil {
+			shapes[s] = &r.routes[i]
+		}
+	}
+	var errs []error
+	for i := range routes {
+		if prev := shapes[routes[i].shape()]; prev != nil {
+			errs = append(errs, fmt.Errorf("route %q conflicts with %q", routes[i].String(), prev.String()))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for i := range routes {
+		routes[i].ref = &CommandHandle{router: r}
+		r.applyExtra(&routes[i])
+		r.routes = append(r.routes, routes[i])
+	}
+	r.trie.Store(nil)
+	return nil
+}
+
+// clone returns a copy of rt that shares no slices or maps with it. The
+// handler, the mounted router and the handle are shared.
+func (rt route) clone() route {
+	rt.parts = slices.Clone(rt.parts)
+	rt.segments = slices.Clone(rt.segments)
+	rt.paramSpecs = maps.Clone(rt.paramSpecs)
+	rt.flags = slices.Clone(rt.flags)
+	rt.examples = slices.Clone(rt.examples)
+	rt.aliases = slices.Clone(rt.aliases)
+	rt.lazy = maps.Clone(rt.lazy)
+	return rt
+}
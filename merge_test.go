@@ -0,0 +1,116 @@
+package clir
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRouter_Merge(t *testing.T) {
+	var ran []string
+	plugins := New()
+	plugins.EnableHelpCommand(true)
+	plugins.Routes(func(b *Builder) {
+		b.With(func(next Handler) Handler {
+			return func(req *Request) error {
+				ran = append(ran, "mw")
+				return next(req)
+			}
+		}).Handle("install <name>", "Install a plugin", func(req *Request) error {
+			ran = append(ran, "install "+req.Params["name"])
+			return nil
+		})
+	})
+	plugins.HandleWith("list", CommandOptions{Desc: "List plugins", Aliases: []string{"ls"}}, func(*Request) error {
+		ran = append(ran, "list")
+		return nil
+	})
+
+	r := New()
+	r.EnableHelpCommand(true)
+	r.Handle("version", "Show version", func(*Request) error { return nil })
+	if err := r.Merge(plugins, "plugin"); err != nil {
+		t.Fatalf("Merge returned error: %v", err)
+	}
+
+	for _, argv := range []string{"plugin install foo", "plugin ls"} {
+		if err := r.Run(context.Background(), strings.Fields(argv)); err != nil {
+			t.Fatalf("Run(%q) returned error: %v", argv, err)
+		}
+	}
+	if got := strings.Join(ran, ", "); got != "mw, install foo, list" {
+		t.Fatalf("unexpected runs: %s", got)
+	}
+
+	var patterns []string
+	for _, c := range r.Commands() {
+		patterns = append(patterns, c.Pattern)
+	}
+	if got := strings.Join(patterns, ", "); got != "help, version, plugin install <name>, plugin list, plugin ls" {
+		t.Fatalf("unexpected commands: %s", got)
+	}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	// Changes to the merged routes don't reach the original.
+	r.Handle("plugin remove <name>", "Remove a plugin", func(*Request) error { return nil })
+	if n := len(plugins.Commands()); n != 4 {
+		t.Fatalf("plugins has %d commands, want 4", n)
+	}
+}
+
+func TestRouter_Merge_Conflict(t *testing.T) {
+	noop := func(*Request) error { return nil }
+	r := New()
+	r.Handle("plugin install <name>", "Install a plugin", noop)
+
+	other := New()
+	other.Handle("list", "List plugins", noop)
+	other.Handle("install <pkg>", "Install a package", noop)
+
+	err := r.Merge(other, "plugin")
+	if err == nil || err.Error() != `route "plugin install <pkg>" conflicts with "plugin install <name>"` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := len(r.Commands()); n != 1 {
+		t.Fatalf("Merge registered %d routes despite the conflict", n-1)
+	}
+
+	if err := r.Merge(other, ""); err != nil {
+		t.Fatalf("Merge without prefix returned error: %v", err)
+	}
+	if err := r.Merge(r, ""); err == nil {
+		t.Fatal("expected merging a router into itself to fail")
+	}
+}
+
+func TestRouter_Merge_KeepsParamSpecs(t *testing.T) {
+	plugins := New()
+	ran := 0
+	plugins.Routes(func(b *Builder) {
+		b.Param("id", Required(), NoFlags()).Handle("show <id> info", "Show a plugin", func(*Request) error {
+			ran++
+			return nil
+		})
+	})
+
+	r := New()
+	if err := r.Merge(plugins, "p"); err != nil {
+		t.Fatalf("Merge returned error: %v", err)
+	}
+
+	var noMatch *NoMatchError
+	for _, argv := range [][]string{{"p", "show", "--x", "info"}, {"p", "show", "", "info"}} {
+		if err := r.Run(context.Background(), argv); !errors.As(err, &noMatch) {
+			t.Errorf("Run(%q) = %v, want no match", argv, err)
+		}
+	}
+	if err := r.Run(context.Background(), []string{"p", "show", "lint", "info"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if ran != 1 {
+		t.Fatalf("handler ran %d times, want 1", ran)
+	}
+}
@@ -0,0 +1,74 @@
+package clir
+
+import "slices"
+
+// Mount hands every invocation starting with path to child: the prefix
+// is stripped and the remaining argv is passed to child.Run, so the
+// child's Params and Extra are computed relative to the mount point.
+//
+// The parent's Before/After hooks run around the dispatch, and so do the
+// child's own hooks. Params captured by path itself are not forwarded.
+// Unless the child has its own SetOutput, it writes to the parent's output.
+//
+// Example:
+//
+//	plugins := clir.New()
+//	plugins.Handle("list", "List plugins", listPlugins)
+//
+//	r.Mount("plugin", plugins)
+//	// "plugin list --all" runs listPlugins with Extra{"--all"}
+func (r *Router) Mount(path string, child *Router) {
+	r.addRoute("Mount", mountRoute(splitPattern(path), child, nil))
+}
+
+// Mount mounts child under the current prefix + path, wrapping the
+// dispatch in the builder's middleware. See Router.Mount.
+func (b *Builder) Mount(path string, child *Router) {
+	full := append(append([]string{}, b.prefix...), splitPattern(path)...)
+	b.router.addRoute("Mount", mountRoute(full, child, b.mws))
+}
+
+// SetInlineMountHelp controls how PrintHelp lists mount points. By default
+// a mount point is shown as a single "<prefix> ..." line; when inline is
+// true the mounted router's commands are listed under the prefix instead.
+func (r *Router) SetInlineMountHelp(inline bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetInlineMountHelp")
+	r.inlineMounts = inline
+}
+
+func mountRoute(parts []string, child *Router, mws []Middleware) route {
+	var h Handler = func(req *Request) error {
+		argv := req.Extra
+		if req.PassThrough != nil {
+			argv = append(append(slices.Clip(argv), "--"), req.PassThrough...)
+		}
+		return child.run(req.Context(), argv, req)
+	}
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return route{
+		parts:    parts,
+		segments: parseSegments(parts),
+		handler:  h,
+		desc:     "Mounted commands",
+		mount:    child,
+	}
+}
+
+// prefixedHelpEntries returns r's help entries as seen from a parent,
+// with the mount point's pattern and sort key prepended.
+func (r *Router) prefixedHelpEntries(mount helpEntry) []helpEntry {
+	unlock := r.readLock()
+	defer unlock()
+
+	entries := r.helpEntries()
+	for i := range entries {
+		entries[i].segs = append(append([]segment{}, mount.segs...), entries[i].segs...)
+		entries[i].pat = mount.pat + " " + entries[i].pat
+		entries[i].sortPat = mount.sortPat + " " + entries[i].sortPat
+	}
+	return entries
+}
@@ -0,0 +1,134 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRouter_Mount_DispatchesStrippedArgv(t *testing.T) {
+	child := New()
+
+	var gotArgs, gotExtra []string
+	var gotParams Params
+	child.Handle("install <name>", "Install a plugin", func(req *Request) error {
+		gotArgs = req.Args
+		gotParams = req.Params
+		gotExtra = req.Extra
+		return nil
+	})
+
+	parent := New()
+	parent.Mount("plugin", child)
+
+	argv := []string{"plugin", "install", "lint", "--force"}
+	if err := parent.Run(context.Background(), argv); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if fmt.Sprint(gotArgs) != "[install lint --force]" {
+		t.Fatalf("unexpected args: %v", gotArgs)
+	}
+	if gotParams["name"] != "lint" {
+		t.Fatalf("unexpected params: %#v", gotParams)
+	}
+	if fmt.Sprint(gotExtra) != "[--force]" {
+		t.Fatalf("unexpected extra: %v", gotExtra)
+	}
+}
+
+func TestRouter_Mount_ChildNoMatch(t *testing.T) {
+	child := New()
+	child.Handle("list", "List plugins", func(req *Request) error { return nil })
+
+	parent := New()
+	parent.Mount("plugin", child)
+
+	err := parent.Run(context.Background(), []string{"plugin", "nope"})
+	if err == nil || !strings.Contains(err.Error(), "no matching command for `nope`") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBuilder_Mount_UsesPrefixAndMiddleware(t *testing.T) {
+	child := New()
+
+	var steps []string
+	child.Handle("list", "List plugins", func(req *Request) error {
+		steps = append(steps, "child")
+		return nil
+	})
+
+	mw := func(next Handler) Handler {
+		return func(req *Request) error {
+			steps = append(steps, "mw")
+			return next(req)
+		}
+	}
+
+	parent := New()
+	parent.Routes(func(b *Builder) {
+		b.With(mw).Route("ext", func(b *Builder) {
+			b.Mount("plugin", child)
+		})
+	})
+
+	if err := parent.Run(context.Background(), []string{"ext", "plugin", "list"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if fmt.Sprint(steps) != "[mw child]" {
+		t.Fatalf("unexpected steps: %v", steps)
+	}
+}
+
+func TestRouter_Mount_Help(t *testing.T) {
+	child := New()
+	child.Handle("list", "List plugins", func(req *Request) error { return nil })
+	child.Handle("install <name>", "Install a plugin", func(req *Request) error { return nil })
+
+	parent := New()
+	parent.Handle("version", "Show version", func(req *Request) error { return nil })
+	parent.Mount("plugin", child)
+
+	var buf bytes.Buffer
+	parent.PrintHelp(&buf)
+	if out := buf.String(); !strings.Contains(out, "plugin ...") || strings.Contains(out, "plugin list") {
+		t.Fatalf("unexpected help output: %q", out)
+	}
+
+	parent.SetInlineMountHelp(true)
+	buf.Reset()
+	parent.PrintHelp(&buf)
+	out := buf.String()
+	for _, want := range []string{"plugin list", "plugin install <name>", "Install a plugin", "version"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("help output missing %q: %q", want, out)
+		}
+	}
+	if strings.Contains(out, "plugin ...") {
+		t.Fatalf("inline help should not show the mount point: %q", out)
+	}
+}
+
+func TestRouter_Mount_InheritsOutput(t *testing.T) {
+	child := New()
+	child.Handle("hello", "Say hello", func(req *Request) error {
+		fmt.Fprint(req.Stdout, "from child")
+		return nil
+	})
+
+	parent := New()
+	parent.Mount("plugin", child)
+
+	var out bytes.Buffer
+	parent.SetOutput(&out, nil)
+
+	if err := parent.Run(context.Background(), []string{"plugin", "hello"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if out.String() != "from child" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
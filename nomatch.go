@@ -0,0 +1,112 @@
+package clir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NoMatchError is returned by Run when no route matches argv. It records
+// how far the closest route got, so the message can point at the first
+// token that went wrong:
+//
+//	no matching command for `comp api image bld`: matched `comp <component> image` but no command for `bld`
+type NoMatchError struct {
+	// Argv is the argv that failed to match, after global flags.
+	Argv []string
+
+	// MatchedPrefix are the leading tokens of Argv matched by the closest
+	// route, and Unmatched the rest. MatchedPrefix is empty if no route
+	// matched even the first token.
+	MatchedPrefix []string
+	Unmatched     []string
+
+	// MatchedPattern is the closest route's pattern, cut to MatchedPrefix,
+	// e.g. "comp <component> image".
+	MatchedPattern string
+
+	hint    string
+	message func(argv []string) string // see Router.SetNoMatchMessage
+	noColor bool                       // the invocation had --no-color
+}
+
+func (e *NoMatchError) Error() string {
+	if e.message != nil {
+		return e.message(e.Argv)
+	}
+	msg := fmt.Sprintf("no matching command for `%s`", strings.Join(e.Argv, " "))
+	switch {
+	case len(e.MatchedPrefix) == 0:
+	case len(e.Unmatched) == 0:
+		msg += fmt.Sprintf(": `%s` is not a complete command", e.MatchedPattern)
+	default:
+		msg += fmt.Sprintf(": matched `%s` but no command for `%s`", e.MatchedPattern, strings.Join(e.Unmatched, " "))
+	}
+	return msg + e.hint
+}
+
+// SetNoMatchMessage replaces the message of the NoMatchError Run returns
+// when nothing matches, e.g. to phrase it for end users or add hints. fn
+// receives the argv that failed to match, after global flags, and its
+// result is the whole message. The error's fields are unaffected. A nil fn
+// restores the default.
+//
+// Example:
+//
+//	r.SetNoMatchMessage(func(argv []string) string {
+//	    return fmt.Sprintf("unknown command %q, run 'mytool help' for a list", strings.Join(argv, " "))
+//	})
+func (r *Router) SetNoMatchMessage(fn func(argv []string) string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetNoMatchMessage")
+	r.noMatchMessage = fn
+}
+
+// noMatch builds the NoMatchError for argv, finding the route whose
+// leading segments match the most tokens. Among those, the best-ranked
+// prefix wins, then the earliest route. Callers hold a read lock.
+func (r *Router) noMatch(argv []string) *NoMatchError {
+	best, bestRank := 0, uint64(0)
+	var bestRt *route
+	for i := range r.routes {
+		rt := &r.routes[i]
+		k, rank := rt.matchPrefix(argv)
+		if k > best || (k == best && k > 0 && rank > bestRank) {
+			best, bestRank, bestRt = k, rank, rt
+		}
+	}
+
+	e := &NoMatchError{
+		Argv:          argv,
+		MatchedPrefix: argv[:best],
+		Unmatched:     argv[best:],
+		message:       r.noMatchMessage,
+	}
+	if bestRt != nil {
+		prefix := route{segments: bestRt.segments[:best]}
+		e.MatchedPattern = prefix.String()
+	}
+	return e
+}
+
+// matchPrefix returns how many leading tokens of argv match rt's leading
+// segments, and the rank of that prefix as in matchArgv.
+func (rt *route) matchPrefix(argv []string) (k int, rank uint64) {
+	for k < len(rt.segments) && k < len(argv) && k < 32 {
+		s := rt.segments[k]
+		var code uint64
+		switch {
+		case !s.matches(argv[k]):
+			return k, rank
+		case s.lit != "":
+			code = 0b11
+		case s.glob != "":
+			code = 0b10
+		default:
+			code = 0b01
+		}
+		rank |= code << uint(2*(32-1-k))
+		k++
+	}
+	return k, rank
+}
@@ -0,0 +1,88 @@
+package clir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRouter_NoMatchError(t *testing.T) {
+	r := New()
+	noop := func(*Request) error { return nil }
+	r.Handle("comp <component> image build", "Build images", noop)
+	r.Handle("comp <component> image push", "Push images", noop)
+	r.Handle("<file> validate", "Validate a file", noop)
+	r.Handle("version", "Show version", noop)
+
+	tests := []struct {
+		argv      string
+		msg       string
+		matched   string
+		unmatched string
+	}{
+		{
+			"comp api image bld --tag x",
+			"no matching command for `comp api image bld --tag x`: matched `comp <component> image` but no command for `bld --tag x`",
+			"[comp api image]", "[bld --tag x]",
+		},
+		{
+			"comp api",
+			"no matching command for `comp api`: `comp <component>` is not a complete command",
+			"[comp api]", "[]",
+		},
+		{
+			// The literal prefix beats the greedy "<file> validate" at equal depth.
+			"comp",
+			"no matching command for `comp`: `comp` is not a complete command",
+			"[comp]", "[]",
+		},
+		{
+			"file.yaml check",
+			"no matching command for `file.yaml check`: matched `<file>` but no command for `check`",
+			"[file.yaml]", "[check]",
+		},
+		{
+			"",
+			"no matching command for ``",
+			"[]", "[]",
+		},
+	}
+
+	for _, tt := range tests {
+		err := r.Run(context.Background(), strings.Fields(tt.argv))
+		var nm *NoMatchError
+		if !errors.As(err, &nm) {
+			t.Fatalf("Run(%q) = %v, want a *NoMatchError", tt.argv, err)
+		}
+		if nm.Error() != tt.msg {
+			t.Errorf("Run(%q) error = %q, want %q", tt.argv, nm.Error(), tt.msg)
+		}
+		if fmt.Sprint(nm.MatchedPrefix) != tt.matched || fmt.Sprint(nm.Unmatched) != tt.unmatched {
+			t.Errorf("Run(%q) prefix = %v, unmatched = %v", tt.argv, nm.MatchedPrefix, nm.Unmatched)
+		}
+	}
+}
+
+func TestRouter_SetNoMatchMessage(t *testing.T) {
+	r := New()
+	r.Handle("version", "Show version", func(*Request) error { return nil })
+	r.SetNoMatchMessage(func(argv []string) string {
+		return fmt.Sprintf("unknown command %q", strings.Join(argv, " "))
+	})
+
+	err := r.Run(context.Background(), []string{"verison", "x"})
+	var nm *NoMatchError
+	if !errors.As(err, &nm) || err.Error() != `unknown command "verison x"` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(nm.Unmatched) != "[verison x]" {
+		t.Fatalf("fields should be unaffected: %+v", nm)
+	}
+
+	r.SetNoMatchMessage(nil)
+	if err := r.Run(context.Background(), []string{"verison"}); err.Error() != "no matching command for `verison`" {
+		t.Fatalf("expected the default message, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package clir
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Printf formats according to a format specifier and writes to
+// Request.Stdout, like fmt.Fprintf. Writing through the Request rather
+// than os.Stdout keeps output capturable in tests and with Serve.
+//
+// Example:
+//
+//	req.Printf("built %s in %s\n", req.Params["component"], req.Elapsed())
+func (r *Request) Printf(format string, args ...any) (int, error) {
+	return fmt.Fprintf(r.stdout(), format, args...)
+}
+
+// Println writes its operands to Request.Stdout, like fmt.Fprintln.
+func (r *Request) Println(args ...any) (int, error) {
+	return fmt.Fprintln(r.stdout(), args...)
+}
+
+// Print writes its operands to Request.Stdout, like fmt.Fprint.
+func (r *Request) Print(args ...any) (int, error) {
+	return fmt.Fprint(r.stdout(), args...)
+}
+
+// stdout returns Stdout, or os.Stdout if it is nil.
+func (r *Request) stdout() io.Writer {
+	if r.Stdout == nil {
+		return os.Stdout
+	}
+	return r.Stdout
+}
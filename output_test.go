@@ -0,0 +1,27 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestRequest_Printf(t *testing.T) {
+	r := New()
+	var out bytes.Buffer
+	r.SetOutput(&out, nil)
+	r.Handle("hello <name>", "Say hello", func(req *Request) error {
+		req.Printf("hello %s\n", req.Params["name"])
+		req.Print("a", "b")
+		req.Println()
+		req.Println("n", 1)
+		return nil
+	})
+
+	if err := r.Run(context.Background(), []string{"hello", "world"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := out.String(); got != "hello world\nab\nn 1\n" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+}
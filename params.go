@@ -0,0 +1,239 @@
+package clir
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// paramSpec holds what was declared about a param with Builder.Param.
+type paramSpec struct {
+	desc     string
+	required bool
+	noFlags  bool
+	kind     string                    // type name for errors, e.g. "int"
+	parse    func(string) (any, error) // nil for plain string params
+}
+
+// ParamOption configures a param declared with Builder.Param.
+type ParamOption func(*paramSpec)
+
+// WithDescription describes a param for per-command help.
+func WithDescription(desc string) ParamOption {
+	return func(s *paramSpec) { s.desc = desc }
+}
+
+// Required makes a param reject an empty token, so argv like
+// `comp "" image build` doesn't match the route and another route may
+// match instead. Without it, a param captures any token, including "".
+func Required() ParamOption {
+	return func(s *paramSpec) { s.required = true }
+}
+
+// NoFlags makes a param refuse tokens that look like flags (see
+// Request.Flag), so in "comp --tag image build" the misplaced --tag isn't
+// taken as the component: the route doesn't match, and another route
+// may match instead or Run reports no match. A lone "-" and negative
+// numbers are still accepted. List params always refuse flags; rest
+// params never do.
+//
+// Example:
+//
+//	b.Param("component", clir.NoFlags()).Handle("comp <component> image build", "Build images", handler)
+func NoFlags() ParamOption {
+	return func(s *paramSpec) { s.noFlags = true }
+}
+
+// AsInt makes a param hold an int, parsed with strconv.Atoi before the
+// handler runs. See Request.Get.
+func AsInt() ParamOption {
+	return typed("int", func(v string) (any, error) { return strconv.Atoi(v) })
+}
+
+// AsBool makes a param hold a bool, parsed with strconv.ParseBool before
+// the handler runs. See Request.Get.
+func AsBool() ParamOption {
+	return typed("bool", func(v string) (any, error) { return strconv.ParseBool(v) })
+}
+
+// AsDuration makes a param hold a time.Duration, parsed with
+// time.ParseDuration before the handler runs. See Request.Get.
+func AsDuration() ParamOption {
+	return typed("duration", func(v string) (any, error) { return time.ParseDuration(v) })
+}
+
+func typed(kind string, parse func(string) (any, error)) ParamOption {
+	return func(s *paramSpec) {
+		s.kind = kind
+		s.parse = parse
+	}
+}
+
+// Get returns the value of the named param: the parsed value if it was
+// declared with a type (AsInt, AsBool, AsDuration), else the string from
+// Params. It returns nil if the matched pattern captured no such param.
+// Types apply to params filling a single token; list and rest params
+// stay in ParamLists as strings.
+//
+// Example:
+//
+//	b.Param("count", clir.AsInt()).Handle("logs tail <count>", "Tail logs", handler)
+//	// argv: logs tail 20
+//	req.Get("count") // 20 (an int)
+func (r *Request) Get(name string) any {
+	if v, ok := r.typed[name]; ok {
+		return v
+	}
+	if v, ok := r.Params[name]; ok {
+		return v
+	}
+	return nil
+}
+
+// Param returns the named param as a T, or the zero T if the param is
+// missing or holds another type. See Request.Get.
+//
+// Example:
+//
+//	n := clir.Param[int](req, "count")
+func Param[T any](req *Request, name string) T {
+	v, _ := req.Get(name).(T)
+	return v
+}
+
+// parseTyped parses the params of req declared with a type into
+// req.typed. Each value that doesn't parse is an error naming the param;
+// all of them are returned, joined.
+func parseTyped(rt *route, req *Request) error {
+	var errs []error
+	for _, s := range rt.segments {
+		if s.last() {
+			continue
+		}
+		for _, name := range []string{s.param, s.key, s.val} {
+			spec := rt.paramSpecs[name]
+			v, ok := req.Params[name]
+			if name == "" || spec.parse == nil || !ok {
+				continue
+			}
+			val, err := spec.parse(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for %s param <%s>", v, spec.kind, name))
+				continue
+			}
+			if req.typed == nil {
+				req.typed = map[string]any{}
+			}
+			req.typed[name] = val
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Param declares options for the param name in routes handled by the
+// returned builder, including those in nested Route calls. Declaring the
+// same name again replaces the earlier options. Per-command help lists
+// every param of the route in an "Arguments:" section, with its
+// description if it has one.
+//
+// Example:
+//
+//	b.Route("comp <component>", func(b *clir.Builder) {
+//	    b = b.Param("component", clir.WithDescription("name of the component"))
+//	    b.Handle("image build", "Build images", handler)
+//	})
+func (b *Builder) Param(name string, opts ...ParamOption) *Builder {
+	var spec paramSpec
+	for _, opt := range opts {
+		opt(&spec)
+	}
+	child := b.derive()
+	if child.paramSpecs == nil {
+		child.paramSpecs = map[string]paramSpec{}
+	}
+	child.paramSpecs[name] = spec
+	return child
+}
+
+// Param declares options for the param name in routes handled by the
+// returned typed builder. See Builder.Param.
+func (b *ContextBuilder[T]) Param(name string, opts ...ParamOption) *ContextBuilder[T] {
+	return &ContextBuilder[T]{
+		base:     b.base.Param(name, opts...),
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: b.resolved,
+	}
+}
+
+// CommandParam describes a param of a Command.
+type CommandParam struct {
+	// Name is the param's name without brackets, e.g. "component".
+	Name string
+
+	// Desc is the description given with WithDescription, or "".
+	Desc string
+
+	// Rest marks a rest segment such as <cmd...>.
+	Rest bool
+
+	// List marks a list segment such as <tags+>.
+	List bool
+}
+
+// commandParams returns rt's params in pattern order.
+func (rt *route) commandParams() []CommandParam {
+	var out []CommandParam
+	for _, s := range rt.segments {
+		switch {
+		case s.param != "":
+			out = append(out, CommandParam{
+				Name: s.param,
+				Desc: rt.paramSpecs[s.param].desc,
+				Rest: s.rest,
+				List: s.list,
+			})
+		case s.key != "":
+			out = append(out,
+				CommandParam{Name: s.key, Desc: rt.paramSpecs[s.key].desc},
+				CommandParam{Name: s.val, Desc: rt.paramSpecs[s.val].desc},
+			)
+		}
+	}
+	return out
+}
+
+// printArguments prints the "Arguments:" section of per-command help,
+// or nothing if rt has no params.
+func printArguments(w io.Writer, rt *route, color bool) {
+	params := rt.commandParams()
+	if len(params) == 0 {
+		return
+	}
+
+	names := make([]string, len(params))
+	maxLen := 0
+	for i, p := range params {
+		names[i] = "<" + p.Name + ">"
+		if p.Rest {
+			names[i] = "<" + p.Name + "...>"
+		}
+		if p.List {
+			names[i] = "<" + p.Name + "+>"
+		}
+		maxLen = max(maxLen, len(names[i]))
+	}
+
+	fmt.Fprintf(w, "\n%s\n", bold("Arguments:", color))
+	for i, p := range params {
+		if p.Desc == "" {
+			fmt.Fprintf(w, "  %s\n", names[i])
+			continue
+		}
+		pad := strings.Repeat(" ", maxLen-len(names[i]))
+		fmt.Fprintf(w, "  %s%s  %s\n", names[i], pad, p.Desc)
+	}
+}
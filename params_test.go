@@ -0,0 +1,153 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParam_Required(t *testing.T) {
+	r := New()
+	var got string
+	handler := func(name string) Handler {
+		return func(*Request) error {
+			got = name
+			return nil
+		}
+	}
+	r.Routes(func(b *Builder) {
+		b.Param("component", Required()).Handle("comp <component> image build", "Build images", handler("required"))
+		b.Handle("comp <name> image build", "Build default images", handler("fallback"))
+		b.Param("id", Required()).Handle("users <id>", "Show user", handler("user"))
+		b.Handle("tag <name>", "Tag", handler("tag"))
+	})
+
+	tests := []struct {
+		argv   []string
+		want   string
+		wantOK bool
+	}{
+		{[]string{"comp", "api", "image", "build"}, "required", true},
+		{[]string{"comp", "", "image", "build"}, "fallback", true},
+		{[]string{"users", "42"}, "user", true},
+		{[]string{"users", ""}, "", false},
+		{[]string{"tag", ""}, "tag", true}, // params without Required accept ""
+	}
+	for _, tt := range tests {
+		got = ""
+		err := r.Run(context.Background(), tt.argv)
+		if (err == nil) != tt.wantOK || got != tt.want {
+			t.Errorf("Run(%q) = %v, handler %q; want %q, ok %v", tt.argv, err, got, tt.want, tt.wantOK)
+		}
+
+		wantRt, _, wantOK := r.bestMatchLinear(context.Background(), tt.argv)
+		gotRt, _, gotOK := r.bestMatch(context.Background(), tt.argv)
+		if gotOK != wantOK || gotRt != wantRt {
+			t.Errorf("trie and linear match disagree for %q", tt.argv)
+		}
+	}
+}
+
+func TestParam_Typed(t *testing.T) {
+	r := New()
+	var got *Request
+	r.Routes(func(b *Builder) {
+		b.Param("count", AsInt()).
+			Param("follow", AsBool()).
+			Param("every", AsDuration()).
+			Handle("logs <service> <count> <follow> <every>", "Tail logs", func(req *Request) error {
+				got = req
+				return nil
+			})
+	})
+
+	if err := r.Run(context.Background(), strings.Fields("logs api 20 true 5s")); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if n := Param[int](got, "count"); n != 20 {
+		t.Errorf("count = %d, want 20", n)
+	}
+	if !Param[bool](got, "follow") {
+		t.Error("follow = false, want true")
+	}
+	if d := Param[time.Duration](got, "every"); d != 5*time.Second {
+		t.Errorf("every = %v, want 5s", d)
+	}
+	if v := got.Get("service"); v != "api" {
+		t.Errorf("Get(service) = %v, want the string param", v)
+	}
+	if v := got.Get("missing"); v != nil {
+		t.Errorf("Get(missing) = %v, want nil", v)
+	}
+	if n := Param[string](got, "count"); n != "" {
+		t.Errorf("Param[string](count) = %q, want the zero value", n)
+	}
+	if got.Params["count"] != "20" {
+		t.Errorf("Params still hold the raw strings, got %q", got.Params["count"])
+	}
+
+	got = nil
+	err := r.Run(context.Background(), strings.Fields("logs api twenty true 5s"))
+	if err == nil || err.Error() != `invalid value "twenty" for int param <count>` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != nil {
+		t.Fatal("handler ran despite the parse failure")
+	}
+
+	err = r.Run(context.Background(), strings.Fields("logs api twenty maybe 5s"))
+	if err == nil || err.Error() != `invalid value "twenty" for int param <count>`+"\n"+`invalid value "maybe" for bool param <follow>` {
+		t.Fatalf("expected every failure to be reported, got: %v", err)
+	}
+}
+
+func TestParam_NoFlags(t *testing.T) {
+	r := New()
+	var got string
+	handler := func(name string) Handler {
+		return func(req *Request) error {
+			got = name + " " + req.Params["component"]
+			return nil
+		}
+	}
+	r.Routes(func(b *Builder) {
+		b.Param("component", NoFlags()).Handle("comp <component> image build", "Build images", handler("build"))
+		b.Handle("comp <name> image <action>", "Image action", handler("action"))
+		b.Param("n", NoFlags()).Handle("seq <n>", "Sequence", func(req *Request) error {
+			got = "seq " + req.Params["n"]
+			return nil
+		})
+	})
+
+	tests := []struct {
+		argv string
+		want string // "" means no match
+	}{
+		{"comp api image build", "build api"},
+		{"comp --tag image build", "action "},
+		{"seq -1", "seq -1"},
+		{"seq -", "seq -"},
+		{"seq --all", ""},
+	}
+	for _, tt := range tests {
+		got = ""
+		argv := strings.Fields(tt.argv)
+		err := r.Run(context.Background(), argv)
+		if (err == nil) != (tt.want != "") || got != tt.want {
+			t.Errorf("Run(%q): got=%q err=%v, want %q", tt.argv, got, err, tt.want)
+		}
+		wantRt, _, wantOK := r.bestMatchLinear(context.Background(), argv)
+		gotRt, _, gotOK := r.bestMatch(context.Background(), argv)
+		if gotOK != wantOK || gotRt != wantRt {
+			t.Errorf("trie and linear match disagree for %q", tt.argv)
+		}
+	}
+
+	var explain bytes.Buffer
+	r.Explain(strings.Fields("seq --all"), &explain)
+	if !strings.Contains(explain.String(), `segment 2 is param <n>, which refuses flags, got "--all"`) {
+		t.Errorf("unexpected explanation:\n%s", explain.String())
+	}
+}
@@ -0,0 +1,41 @@
+package clir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetStripProgramName controls whether Run drops a leading program name
+// from argv, for callers that pass os.Args instead of os.Args[1:]. A first
+// token is taken as the program name if it equals os.Args[0] or its base
+// name is the router's name (see SetName). It is off by default; without
+// it, such argv still fails to match, but the error points out the likely
+// mistake. Mounted routers never strip.
+func (r *Router) SetStripProgramName(strip bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetStripProgramName")
+	r.stripProg = strip
+}
+
+// isProgramName reports whether tok names the running program.
+// Callers hold a read lock.
+func (r *Router) isProgramName(tok string) bool {
+	if len(os.Args) > 0 && tok == os.Args[0] {
+		return true
+	}
+	return r.name != "" && filepath.Base(tok) == r.name
+}
+
+// programNameHint returns a hint for a no-match error when argv looks like
+// it starts with the program name, or "". Callers hold a read lock.
+func (r *Router) programNameHint(argv []string) string {
+	if len(argv) == 0 {
+		return ""
+	}
+	if !r.isProgramName(argv[0]) && !strings.ContainsRune(argv[0], '/') {
+		return ""
+	}
+	return " (the first argument looks like the program name; pass os.Args[1:], use RunArgs or enable SetStripProgramName)"
+}
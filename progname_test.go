@@ -0,0 +1,46 @@
+package clir
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRouter_SetStripProgramName(t *testing.T) {
+	r := New()
+	r.SetName("mytool")
+
+	var called bool
+	r.Handle("version", "Show version", func(req *Request) error {
+		called = true
+		return nil
+	})
+
+	err := r.Run(context.Background(), []string{"/usr/local/bin/mytool", "version"})
+	if err == nil || !strings.Contains(err.Error(), "looks like the program name") {
+		t.Fatalf("expected a program-name hint, got %v", err)
+	}
+
+	r.SetStripProgramName(true)
+	for _, argv := range [][]string{
+		{"/usr/local/bin/mytool", "version"},
+		{"mytool", "version"},
+		{"version"},
+	} {
+		called = false
+		if err := r.Run(context.Background(), argv); err != nil || !called {
+			t.Fatalf("Run(%v) = %v, called = %v", argv, err, called)
+		}
+	}
+}
+
+func TestRouter_ProgramNameHint_OnlyForPathLikeTokens(t *testing.T) {
+	r := New()
+	r.SetName("mytool")
+	r.Handle("version", "Show version", func(*Request) error { return nil })
+
+	err := r.Run(context.Background(), []string{"verison"})
+	if err == nil || err.Error() != "no matching command for `verison`" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package clir
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CommandSpec declares a command for Router.Register: a pattern and
+// handler, plus the metadata HandleWith takes.
+type CommandSpec struct {
+	// Pattern is the route pattern, as passed to Handle.
+	Pattern string
+
+	// Handler runs the command. Required.
+	Handler Handler
+
+	CommandOptions
+}
+
+// Register registers the commands in specs, in order, as if HandleWith
+// were called for each, so command sets can come from a registry or
+// generated code. The batch is checked first: invalid patterns or
+// aliases, duplicate params, missing handlers and patterns that would
+// match the same argv with the same rank as an earlier spec or a route
+// already registered are all reported, joined into one error, and
+// nothing is registered.
+//
+// Example:
+//
+//	err := r.Register([]clir.CommandSpec{
+//	    {Pattern: "version", Handler: showVersion,
+//	        CommandOptions: clir.CommandOptions{Desc: "Show version"}},
+//	    {Pattern: "remote remove <name>", Handler: removeRemote,
+//	        CommandOptions: clir.CommandOptions{Desc: "Remove a remote", Aliases: []string{"remote rm <name>"}}},
+//	})
+func (r *Router) Register(specs []CommandSpec) error {
+	unlock := r.readLock()
+	shapes := map[string]string{}
+	for i := range r.routes {
+		if s := r.routes[i].shape(); shapes[s] == "" {
+			shapes[s] = r.routes[i].String()
+		}
+	}
+	unlock()
+
+	var errs []error
+	check := func(pattern string) {
+		parts := splitPattern(pattern)
+		if err := checkParts(parts); err != nil {
+			errs = append(errs, fmt.Errorf("invalid pattern %q: %w", pattern, err))
+			return
+		}
+		rt := &route{parts: parts, segments: parseSegments(parts)}
+		if name, ok := rt.duplicateParam(); ok {
+			errs = append(errs, fmt.Errorf("duplicate param <%s> in pattern %q", name, rt.String()))
+			return
+		}
+		if prev, ok := shapes[rt.shape()]; ok {
+			errs = append(errs, fmt.Errorf("route %q conflicts with %q", rt.String(), prev))
+			return
+		}
+		shapes[rt.shape()] = rt.String()
+	}
+	for _, s := range specs {
+		if s.Handler == nil {
+			errs = append(errs, fmt.Errorf("route %q: no handler", s.Pattern))
+		}
+		check(s.Pattern)
+		for _, a := range s.Aliases {
+			check(a)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, s := range specs {
+		r.HandleWith(s.Pattern, s.CommandOptions, s.Handler)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package clir
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRouter_Register(t *testing.T) {
+	r := New()
+	var got string
+	handler := func(name string) Handler {
+		return func(req *Request) error {
+			got = name + " " + req.Params["name"]
+			return nil
+		}
+	}
+	err := r.Register([]CommandSpec{
+		{Pattern: "version", Handler: handler("version"),
+			CommandOptions: CommandOptions{Desc: "Show version"}},
+		{Pattern: "remote remove <name>", Handler: handler("remove"),
+			CommandOptions: CommandOptions{Desc: "Remove a remote", Aliases: []string{"remote rm <name>"}}},
+	})
+	if err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+	if err := r.Run(context.Background(), strings.Fields("remote rm origin")); err != nil || got != "remove origin" {
+		t.Fatalf("alias: got=%q err=%v", got, err)
+	}
+	var patterns []string
+	for _, c := range r.Commands() {
+		patterns = append(patterns, c.Pattern)
+	}
+	if s := strings.Join(patterns, ", "); s != "version, remote remove <name>, remote rm <name>" {
+		t.Fatalf("unexpected commands: %s", s)
+	}
+}
+
+func TestRouter_Register_ValidatesBatch(t *testing.T) {
+	r := New()
+	noop := func(*Request) error { return nil }
+	r.Handle("version", "Show version", noop)
+
+	err := r.Register([]CommandSpec{
+		{Pattern: "status", Handler: noop},
+		{Pattern: "cp <x> <x>", Handler: noop},
+		{Pattern: "log", Handler: nil},
+		{Pattern: "version", Handler: noop},
+		{Pattern: "show <id>", Handler: noop, CommandOptions: CommandOptions{Aliases: []string{"status"}}},
+	})
+	want := `duplicate param <x> in pattern "cp <x> <x>"` + "\n" +
+		`route "log": no handler` + "\n" +
+		`route "version" conflicts with "version"` + "\n" +
+		`route "status" conflicts with "status"`
+	if err == nil || err.Error() != want {
+		t.Fatalf("unexpected error:\n%v\nwant:\n%s", err, want)
+	}
+	if n := len(r.Commands()); n != 1 {
+		t.Fatalf("Register registered routes despite errors: %d commands", n)
+	}
+}
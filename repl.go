@@ -0,0 +1,101 @@
+package clir
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// replPrompt is printed before each line read by REPL.
+const replPrompt = "> "
+
+// REPL runs an interactive loop: it prints a prompt to out, reads a line
+// from in, runs it with RunLine and prints any error to out, except for an
+// ErrShowHelp already answered with the command's help. Blank lines are
+// skipped; "exit" or "quit" ends the loop.
+//
+// Handler output goes to out unless the router has its own SetOutput.
+// Every line shares one Session (see Request.Session), so commands can
+// leave state for later ones.
+// REPL returns nil on exit or EOF, ctx.Err() when ctx is cancelled (even
+// while waiting for input), or the error from reading in.
+//
+// Example:
+//
+//	if err := r.REPL(ctx, os.Stdin, os.Stdout); err != nil {
+//	    log.Fatal(err)
+//	}
+func (r *Router) REPL(ctx context.Context, in io.Reader, out io.Writer) error {
+	lines, readErr, stop := readLines(in)
+	defer stop()
+	ctx = r.lineSession(ctx)
+
+	parent := &Request{Stdout: out, Stderr: out}
+	for {
+		fmt.Fprint(out, replPrompt)
+
+		var line string
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(out)
+			return ctx.Err()
+		case err := <-readErr:
+			fmt.Fprintln(out)
+			return err
+		case line = <-lines:
+		}
+
+		line = strings.TrimSpace(line)
+		switch line {
+		case "":
+			continue
+		case "exit", "quit":
+			return nil
+		}
+
+		argv, err := r.splitLine(line)
+		if err == nil {
+			err = r.run(ctx, argv, parent)
+		}
+		var shown *helpShownError
+		if err != nil && !errors.As(err, &shown) {
+			fmt.Fprintln(out, "Error:", err)
+		}
+	}
+}
+
+// readLines reads lines from in in a goroutine, so callers waiting for
+// the next line can still react to cancellation. The error channel
+// receives the read error, or nil at EOF. stop ends the goroutine.
+func readLines(in io.Reader) (lines <-chan string, readErr <-chan error, stop func()) {
+	ch := make(chan string)
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		sc := bufio.NewScanner(in)
+		for sc.Scan() {
+			select {
+			case ch <- sc.Text():
+			case <-done:
+				return
+			}
+		}
+		errc <- sc.Err()
+	}()
+	return ch, errc, func() { close(done) }
+}
+
+// lineSession returns ctx carrying the session shared by the lines of a
+// REPL or Serve loop: the router's own, or a new one.
+func (r *Router) lineSession(ctx context.Context) context.Context {
+	unlock := r.readLock()
+	session := r.session
+	unlock()
+	if session == nil {
+		session = NewSession()
+	}
+	return withSession(ctx, session)
+}
@@ -0,0 +1,122 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newREPLRouter() *Router {
+	r := New()
+	r.Handle("hello <name>", "Say hello", func(req *Request) error {
+		fmt.Fprintf(req.Stdout, "hello %s\n", req.Params["name"])
+		return nil
+	})
+	return r
+}
+
+func TestRouter_REPL(t *testing.T) {
+	r := newREPLRouter()
+
+	in := strings.NewReader("hello world\n\n  \nnope\nhello \"big world\"\nexit\nhello ignored\n")
+	var out bytes.Buffer
+
+	if err := r.REPL(context.Background(), in, &out); err != nil {
+		t.Fatalf("REPL returned error: %v", err)
+	}
+
+	want := "> hello world\n" +
+		"> > > Error: no matching command for `nope`\n" +
+		"> hello big world\n" +
+		"> "
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
+
+func TestRouter_REPL_EOF(t *testing.T) {
+	r := newREPLRouter()
+
+	var out bytes.Buffer
+	if err := r.REPL(context.Background(), strings.NewReader("hello a"), &out); err != nil {
+		t.Fatalf("REPL returned error: %v", err)
+	}
+	if out.String() != "> hello a\n> \n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestRouter_REPL_ContextCancelled(t *testing.T) {
+	r := newREPLRouter()
+
+	// A reader that never returns keeps the REPL waiting for input.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := r.REPL(ctx, pr, io.Discard)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+}
+
+func TestRouter_REPL_SessionPersists(t *testing.T) {
+	r := New()
+	r.Handle("use <component>", "Select a component", func(req *Request) error {
+		req.Session().Set("component", req.Params["component"])
+		return nil
+	})
+	r.Handle("build", "Build the current component", func(req *Request) error {
+		c := req.Session().GetString("component")
+		if c == "" {
+			return errors.New("no component selected")
+		}
+		fmt.Fprintf(req.Stdout, "building %s\n", c)
+		return nil
+	})
+
+	in := strings.NewReader("build\nuse api\nbuild\n")
+	var out bytes.Buffer
+	if err := r.REPL(context.Background(), in, &out); err != nil {
+		t.Fatalf("REPL returned error: %v", err)
+	}
+
+	want := "> Error: no component selected\n> > building api\n> \n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%q\nwant:\n%q", out.String(), want)
+	}
+}
+
+func TestRouter_SetSession(t *testing.T) {
+	r := New()
+	s := NewSession()
+	s.Set("env", "prod")
+	r.SetSession(s)
+
+	var got string
+	r.Handle("deploy", "Deploy", func(req *Request) error {
+		got = req.Session().GetString("env")
+		req.Session().Delete("env")
+		return nil
+	})
+
+	if err := r.Run(context.Background(), []string{"deploy"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got != "prod" {
+		t.Fatalf("unexpected session value: %q", got)
+	}
+	if _, ok := s.Get("env"); ok {
+		t.Fatal("Delete through the request should affect the shared session")
+	}
+	if (&Request{}).Session() != nil {
+		t.Fatal("expected nil session without SetSession or REPL")
+	}
+}
@@ -0,0 +1,71 @@
+package clir
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxResponseFileDepth limits how deeply response files may include
+// other response files.
+const maxResponseFileDepth = 10
+
+// EnableResponseFiles turns response files on or off. When enabled, Run
+// replaces every argv token of the form @file with the contents of file,
+// split on whitespace, before PreRun hooks and matching, as gcc and MSVC
+// do. Quotes in the file have no special meaning. Response files may
+// name further response files, up to 10 levels deep. Tokens after "--"
+// are left alone, and so are a lone "@" and tokens not starting with "@".
+// A file that can't be read fails Run with an error naming it.
+//
+// Example:
+//
+//	r.EnableResponseFiles(true)
+//	// args.txt: "build --tag v1\n--push"
+//	// "mytool comp api image @args.txt" runs "comp api image build --tag v1 --push"
+func (r *Router) EnableResponseFiles(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("EnableResponseFiles")
+	r.responseFiles = enabled
+}
+
+// expandResponseFiles returns argv with @file tokens replaced by the
+// files' contents. It returns argv itself if there are none.
+func expandResponseFiles(argv []string, depth int) ([]string, error) {
+	var out []string
+	for i, tok := range argv {
+		if tok == "--" {
+			if out != nil {
+				out = append(out, argv[i:]...)
+			}
+			break
+		}
+		name, ok := strings.CutPrefix(tok, "@")
+		if !ok || name == "" {
+			if out != nil {
+				out = append(out, tok)
+			}
+			continue
+		}
+		if depth >= maxResponseFileDepth {
+			return nil, fmt.Errorf("response file %s: nested more than %d levels deep", tok, maxResponseFileDepth)
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("response file %s: %w", tok, err)
+		}
+		expanded, err := expandResponseFiles(strings.Fields(string(data)), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = append([]string{}, argv[:i]...)
+		}
+		out = append(out, expanded...)
+	}
+	if out == nil {
+		return argv, nil
+	}
+	return out, nil
+}
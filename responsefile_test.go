@@ -0,0 +1,57 @@
+package clir
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRouter_EnableResponseFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	inner := write("inner.txt", "--push\n")
+	args := write("args.txt", "build --tag v1\n@"+inner+"\n")
+	loop := write("loop.txt", "")
+	write("loop.txt", "@"+loop)
+
+	r := New()
+	var got *Request
+	r.Handle("comp <component> image build", "Build images", func(req *Request) error {
+		got = req
+		return nil
+	})
+
+	argv := []string{"comp", "api", "image", "@" + args}
+	if err := r.Run(context.Background(), argv); err == nil {
+		t.Fatal("response files should be off by default")
+	}
+
+	r.EnableResponseFiles(true)
+	if err := r.Run(context.Background(), append(argv, "--", "@"+args)); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if fmt.Sprint(got.Extra) != "[--tag v1 --push]" || fmt.Sprint(got.PassThrough) != "[@"+args+"]" {
+		t.Fatalf("unexpected expansion: extra=%v pass=%v", got.Extra, got.PassThrough)
+	}
+
+	for _, tt := range []struct {
+		file, want string
+	}{
+		{filepath.Join(dir, "missing.txt"), "missing.txt: "},
+		{loop, "nested more than 10 levels deep"},
+	} {
+		err := r.Run(context.Background(), []string{"comp", "api", "image", "@" + tt.file})
+		if err == nil || !strings.Contains(err.Error(), "response file @") || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Run(@%s) error = %v, want it to contain %q", tt.file, err, tt.want)
+		}
+	}
+}
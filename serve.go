@@ -0,0 +1,68 @@
+package clir
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Serve exposes the router over a line protocol on rw, typically a
+// net.Conn, so test harnesses or other programs can drive the CLI: it
+// reads a command line, runs it like RunLine and writes the handler's
+// output to rw, followed by a status line of its own, "OK" on success or
+// "ERR <message>" on failure, with newlines in the message replaced by
+// spaces. Blank lines are skipped, and there is no prompt.
+//
+// Handler output goes to rw unless the router has its own SetOutput.
+// Like in REPL, every line shares one Session. Serve returns nil at EOF,
+// ctx.Err() when ctx is cancelled (even while waiting for input), or the
+// error from reading or writing rw.
+//
+// Example:
+//
+//	ln, _ := net.Listen("unix", sock)
+//	for {
+//	    conn, err := ln.Accept()
+//	    if err != nil {
+//	        return err
+//	    }
+//	    go func() {
+//	        defer conn.Close()
+//	        r.Serve(ctx, conn)
+//	    }()
+//	}
+func (r *Router) Serve(ctx context.Context, rw io.ReadWriter) error {
+	lines, readErr, stop := readLines(rw)
+	defer stop()
+	ctx = r.lineSession(ctx)
+
+	parent := &Request{Stdout: rw, Stderr: rw}
+	for {
+		var line string
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case line = <-lines:
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		argv, err := r.splitLine(line)
+		if err == nil {
+			err = r.run(ctx, argv, parent)
+		}
+		status := "OK"
+		if err != nil {
+			status = "ERR " + strings.Join(strings.Fields(err.Error()), " ")
+		}
+		if _, err := fmt.Fprintln(rw, status); err != nil {
+			return err
+		}
+	}
+}
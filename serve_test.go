@@ -0,0 +1,53 @@
+package clir
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestRouter_Serve(t *testing.T) {
+	r := newREPLRouter()
+	r.Handle("count", "Count calls", func(req *Request) error {
+		n, _ := req.Session().Get("n")
+		c, _ := n.(int)
+		req.Session().Set("n", c+1)
+		fmt.Fprintln(req.Stdout, c+1)
+		return nil
+	})
+
+	server, client := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Serve(context.Background(), server)
+		server.Close()
+	}()
+
+	out := bufio.NewReader(client)
+	for _, tt := range []struct {
+		line string
+		want []string
+	}{
+		{`hello "big world"`, []string{"hello big world", "OK"}},
+		{"nope", []string{"ERR no matching command for `nope`"}},
+		{"count", []string{"1", "OK"}},
+		{"count", []string{"2", "OK"}},
+	} {
+		if _, err := fmt.Fprintln(client, tt.line); err != nil {
+			t.Fatalf("write %q: %v", tt.line, err)
+		}
+		for _, want := range tt.want {
+			got, err := out.ReadString('\n')
+			if err != nil || got != want+"\n" {
+				t.Fatalf("after %q: read %q, %v; want %q", tt.line, got, err, want)
+			}
+		}
+	}
+
+	client.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+}
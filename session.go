@@ -0,0 +1,83 @@
+package clir
+
+import (
+	"context"
+	"sync"
+)
+
+// Session holds values that persist across invocations, e.g. the
+// "current component" selected by a `use <component>` command in a REPL.
+// It is safe for concurrent use.
+type Session struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+// NewSession returns an empty Session.
+func NewSession() *Session {
+	return &Session{values: map[string]any{}}
+}
+
+// Get returns the value stored under key.
+func (s *Session) Get(key string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// GetString returns the string stored under key, or "" if there is none
+// or it isn't a string.
+func (s *Session) GetString(key string) string {
+	v, _ := s.Get(key)
+	str, _ := v.(string)
+	return str
+}
+
+// Set stores v under key.
+func (s *Session) Set(key string, v any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = v
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+}
+
+type sessionKey struct{}
+
+// SetSession makes s available to every Request dispatched by the router
+// through Request.Session. REPL uses the router's session if set, and
+// otherwise creates one that lives for the duration of the loop.
+func (r *Router) SetSession(s *Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("SetSession")
+	r.session = s
+}
+
+// Session returns the session for this invocation, or nil if neither
+// Router.SetSession nor REPL provided one.
+//
+// Example:
+//
+//	b.Handle("use <component>", "Select a component", func(req *clir.Request) error {
+//	    req.Session().Set("component", req.Params["component"])
+//	    return nil
+//	})
+func (r *Request) Session() *Session {
+	s, _ := r.Context().Value(sessionKey{}).(*Session)
+	return s
+}
+
+// withSession returns ctx carrying s, unless ctx already carries one.
+func withSession(ctx context.Context, s *Session) context.Context {
+	if s == nil || ctx.Value(sessionKey{}) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionKey{}, s)
+}
@@ -0,0 +1,223 @@
+package clir
+
+import (
+	"fmt"
+	"strings"
+)
+
+// strictMode controls which leftover tokens a route rejects.
+type strictMode uint8
+
+const (
+	strictOff  strictMode = iota
+	strictAll             // reject any Extra token
+	strictArgs            // reject positionals only, see Request.Positionals
+)
+
+// extraMode is what a route declares about leftover tokens.
+type extraMode uint8
+
+const (
+	extraDefault extraMode = iota // exact if the router disallows Extra
+	extraExact                    // see Builder.Exact
+	extraAllow                    // see Builder.AllowExtra
+)
+
+// DisallowExtra makes every route match only when no tokens are left over
+// for Extra, as if declared with Builder.Exact, so junk arguments lead to
+// a no-match error instead of being ignored. Routes declared with
+// Builder.AllowExtra, mount points and the help command still take
+// leftover tokens. It applies to routes registered before and after the
+// call.
+//
+// Example:
+//
+//	r.DisallowExtra(true)
+//	r.Handle("version", "Show version", showVersion)
+//	r.Routes(func(b *clir.Builder) {
+//	    b.AllowExtra().Handle("build", "Build", build) // parses its own flags
+//	})
+//	// "mytool version foo" fails with a no-match error
+func (r *Router) DisallowExtra(disallow bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mustNotBeFrozen("DisallowExtra")
+	r.disallowExtra = disallow
+	for i := range r.routes {
+		r.applyExtra(&r.routes[i])
+	}
+	r.trie.Store(nil)
+}
+
+// routerFlagLen returns how many leading tokens are a flag the router
+// handles itself for rt: --no-color, --dry-run if dryRun is set, or
+// --timeout and its value on routes with a timeout. It is 0 if tokens
+// don't start with one.
+func routerFlagLen(rt *route, tokens []string, dryRun bool) int {
+	name, _, hasValue, isFlag := flagName(tokens[0])
+	switch {
+	case !isFlag:
+		return 0
+	case name == noColorFlag, name == dryRunFlag && dryRun:
+		return 1
+	case name == "timeout" && rt.timeout > 0:
+		if hasValue {
+			return 1
+		}
+		return min(2, len(tokens))
+	}
+	return 0
+}
+
+// leavesExtra reports whether rt, if exact, would leave Extra for argv
+// other than the flags the router handles itself (see routerFlagLen).
+func (rt *route) leavesExtra(argv []string) bool {
+	if !rt.exact {
+		return false
+	}
+	rest := rt.leftover(argv)
+	for len(rest) > 0 {
+		n := routerFlagLen(rt, rest, rt.dryRun)
+		if n == 0 {
+			return true
+		}
+		rest = rest[n:]
+	}
+	return false
+}
+
+// applyExtra sets rt.exact from its declared mode and the router default,
+// and records whether the router handles --dry-run. Callers hold r.mu.
+func (r *Router) applyExtra(rt *route) {
+	rt.dryRun = r.dryRun
+	switch rt.extra {
+	case extraExact:
+		rt.exact = true
+	case extraAllow:
+		rt.exact = false
+	default:
+		rt.exact = r.disallowExtra && rt.mount == nil && !rt.builtin
+	}
+}
+
+// Strict makes routes handled by the returned builder reject leftover
+// arguments: when Extra is non-empty after matching, Run returns an error
+// naming the unexpected tokens instead of calling the handler, so
+// "mytool version foo" fails rather than ignoring foo. Flags handled by
+// the router itself (--no-color, --dry-run, --timeout) are still allowed.
+// Tokens after "--" are in PassThrough and never rejected.
+//
+// Example:
+//
+//	b.Strict().Handle("version", "Show version", showVersion)
+//	// "mytool version foo" fails with "unexpected arguments for `version`: `foo`"
+func (b *Builder) Strict() *Builder {
+	child := b.derive()
+	child.strict = strictAll
+	return child
+}
+
+// Exact makes routes handled by the returned builder match only when no
+// tokens are left over for Extra. Unlike Strict, which rejects such argv
+// with an error, Exact lets a lower-ranked route handle it. Ranking is
+// unchanged among routes that do match. Tokens after "--" don't count,
+// and neither do the flags the router handles itself (--no-color,
+// --dry-run, --timeout), as with Strict.
+//
+// Example:
+//
+//	b.Exact().Handle("ls", "List commands", listCommands)
+//	b.Handle("<cmd...>", "Run a program", runProgram)
+//	// "mytool ls" runs listCommands; "mytool ls -la" runs runProgram
+func (b *Builder) Exact() *Builder {
+	child := b.derive()
+	child.extra = extraExact
+	return child
+}
+
+// AllowExtra makes routes handled by the returned builder take leftover
+// tokens into Extra even when the router disallows them. See
+// Router.DisallowExtra.
+func (b *Builder) AllowExtra() *Builder {
+	child := b.derive()
+	child.extra = extraAllow
+	return child
+}
+
+// AllowExtra makes routes handled by the returned typed builder take
+// leftover tokens into Extra. See Builder.AllowExtra.
+func (b *ContextBuilder[T]) AllowExtra() *ContextBuilder[T] {
+	return &ContextBuilder[T]{
+		base:     b.base.AllowExtra(),
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: b.resolved,
+	}
+}
+
+// Exact makes routes handled by the returned typed builder match only
+// without leftover tokens. See Builder.Exact.
+func (b *ContextBuilder[T]) Exact() *ContextBuilder[T] {
+	return &ContextBuilder[T]{
+		base:     b.base.Exact(),
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: b.resolved,
+	}
+}
+
+// StrictArgs is like Strict but only rejects positional arguments (see
+// Request.Positionals), leaving flags for the handler to parse. Unlike
+// with Strict, tokens after "--" are positionals and rejected too.
+func (b *Builder) StrictArgs() *Builder {
+	child := b.derive()
+	child.strict = strictArgs
+	return child
+}
+
+// Strict makes routes handled by the returned typed builder reject
+// leftover arguments. See Builder.Strict.
+func (b *ContextBuilder[T]) Strict() *ContextBuilder[T] {
+	return &ContextBuilder[T]{
+		base:     b.base.Strict(),
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: b.resolved,
+	}
+}
+
+// StrictArgs makes routes handled by the returned typed builder reject
+// leftover positional arguments. See Builder.StrictArgs.
+func (b *ContextBuilder[T]) StrictArgs() *ContextBuilder[T] {
+	return &ContextBuilder[T]{
+		base:     b.base.StrictArgs(),
+		resolve:  b.resolve,
+		inject:   b.inject,
+		resolved: b.resolved,
+	}
+}
+
+// checkStrict returns an error naming the tokens of req.Extra a strict
+// route does not accept, or nil. dryRun reports whether --dry-run is
+// enabled on the router.
+func checkStrict(rt *route, req *Request, dryRun bool) error {
+	var tokens []string
+	switch rt.strict {
+	case strictOff:
+		return nil
+	case strictArgs:
+		tokens = req.Positionals()
+	case strictAll:
+		for i := 0; i < len(req.Extra); i++ {
+			if n := routerFlagLen(rt, req.Extra[i:], dryRun); n > 0 {
+				i += n - 1
+				continue
+			}
+			tokens = append(tokens, req.Extra[i])
+		}
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	return fmt.Errorf("unexpected arguments for `%s`: `%s`", rt.String(), strings.Join(tokens, " "))
+}
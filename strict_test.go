@@ -0,0 +1,210 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuilder_Strict(t *testing.T) {
+	r := New()
+	r.EnableDryRun(true)
+	ran := 0
+	h := func(*Request) error {
+		ran++
+		return nil
+	}
+	r.Routes(func(b *Builder) {
+		b.Strict().Handle("version", "Show version", h)
+		b.Strict().Timeout(time.Minute).Handle("sync", "Sync state", h)
+		b.StrictArgs().Handle("status", "Show status", h)
+		b.Handle("loose", "Accept anything", h)
+	})
+
+	tests := []struct {
+		argv    string
+		wantErr string
+	}{
+		{"version", ""},
+		{"version --no-color", ""},
+		{"version -- foo", ""},
+		{"version foo bar", "unexpected arguments for `version`: `foo bar`"},
+		{"version --short", "unexpected arguments for `version`: `--short`"},
+		{"sync --timeout 2m", ""},
+		{"sync --timeout=2m x", "unexpected arguments for `sync`: `x`"},
+		{"status --short", ""},
+		{"status foo", "unexpected arguments for `status`: `foo`"},
+		{"status -- -x", "unexpected arguments for `status`: `-x`"},
+		{"loose foo", ""},
+	}
+	for _, tt := range tests {
+		err := r.Run(context.Background(), strings.Fields(tt.argv))
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("Run(%q) returned error: %v", tt.argv, err)
+		}
+		if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+			t.Errorf("Run(%q) error = %v, want %q", tt.argv, err, tt.wantErr)
+		}
+	}
+	if ran != 6 {
+		t.Fatalf("expected 6 handler runs, got %d", ran)
+	}
+}
+
+func TestBuilder_Exact(t *testing.T) {
+	r := New()
+	var got string
+	handler := func(name string) Handler {
+		return func(*Request) error {
+			got = name
+			return nil
+		}
+	}
+	r.Routes(func(b *Builder) {
+		b.Exact().Handle("ls", "List commands", handler("ls"))
+		b.Handle("<cmd...>", "Run a program", handler("exec"))
+		b.Exact().Handle("show", "Show a summary", handler("summary"))
+		b.Handle("show <id>", "Show an item", handler("item"))
+		b.Exact().Handle("tag <tags+>", "Tag", handler("tag"))
+		b.Exact().Handle("sync", "Sync", handler("sync"))
+	})
+
+	tests := []struct {
+		argv string
+		want string
+	}{
+		{"ls", "ls"},
+		{"ls -la", "exec"},
+		{"ls -- -la", "ls"},
+		{"show", "summary"},
+		{"show 1", "item"},
+		{"show 1 2", "item"},
+		{"tag a b", "tag"},
+		{"tag a --push", "exec"},
+		{"sync", "sync"},
+		{"sync now", "exec"},
+	}
+	for _, tt := range tests {
+		got = ""
+		argv := strings.Fields(tt.argv)
+		if err := r.Run(context.Background(), argv); err != nil || got != tt.want {
+			t.Errorf("Run(%q): got=%q err=%v, want %q", tt.argv, got, err, tt.want)
+		}
+		if i := slices.Index(argv, "--"); i >= 0 {
+			argv = argv[:i]
+		}
+		wantRt, _, _ := r.bestMatchLinear(context.Background(), argv)
+		gotRt, _, _ := r.bestMatch(context.Background(), argv)
+		if gotRt != wantRt {
+			t.Errorf("%q: trie matched %v, linear matched %v", tt.argv, gotRt, wantRt)
+		}
+	}
+
+	sync := &r.routes[len(r.routes)-1]
+	if got := sync.explain([]string{"sync", "now"}); got != "no match: exact route leaves extra args" {
+		t.Errorf("unexpected explanation: %q", got)
+	}
+}
+
+func TestExactRoutes_AllowRouterFlags(t *testing.T) {
+	noop := func(*Request) error { return nil }
+	for _, mode := range []string{"Exact", "DisallowExtra"} {
+		r := New()
+		var out bytes.Buffer
+		r.SetOutput(&out, &out)
+		r.EnableDryRun(true)
+		if mode == "DisallowExtra" {
+			r.DisallowExtra(true)
+		}
+		r.Routes(func(b *Builder) {
+			if mode == "Exact" {
+				b = b.Exact()
+			}
+			b.Handle("version", "Show version", noop)
+			b.Timeout(time.Minute).Handle("sync", "Sync", noop)
+		})
+
+		for _, argv := range []string{
+			"version --no-color",
+			"version --dry-run",
+			"sync --timeout 5s",
+			"sync --timeout=5s --no-color",
+		} {
+			if err := r.Run(context.Background(), strings.Fields(argv)); err != nil {
+				t.Errorf("%s: Run(%q) returned error: %v", mode, argv, err)
+			}
+		}
+		if !strings.Contains(out.String(), "Dry run:") {
+			t.Errorf("%s: --dry-run did not print a plan: %q", mode, out.String())
+		}
+
+		var noMatch *NoMatchError
+		for _, argv := range []string{"version --timeout 5s", "sync --timeout 5s now"} {
+			if err := r.Run(context.Background(), strings.Fields(argv)); !errors.As(err, &noMatch) {
+				t.Errorf("%s: Run(%q) = %v, want no match", mode, argv, err)
+			}
+		}
+		r.EnableDryRun(false)
+		if err := r.Run(context.Background(), strings.Fields("version --dry-run")); !errors.As(err, &noMatch) {
+			t.Errorf("%s: --dry-run without EnableDryRun = %v, want no match", mode, err)
+		}
+	}
+}
+
+func TestRouter_DisallowExtra(t *testing.T) {
+	r := New()
+	r.EnableHelpCommand(true)
+	var got string
+	handler := func(name string) Handler {
+		return func(req *Request) error {
+			got = strings.TrimSpace(name + " " + strings.Join(req.Extra, " "))
+			return nil
+		}
+	}
+	plugins := New()
+	plugins.Handle("list", "List plugins", handler("plugins"))
+
+	r.Handle("version", "Show version", handler("version"))
+	r.DisallowExtra(true)
+	r.Routes(func(b *Builder) {
+		b.Handle("show <id>", "Show an item", handler("show"))
+		b.AllowExtra().Handle("build", "Build", handler("build"))
+		b.Handle("run <cmd...>", "Run a program", handler("run"))
+	})
+	r.Mount("plugin", plugins)
+
+	tests := []struct {
+		argv string
+		want string // "" means no match
+	}{
+		{"version", "version"},
+		{"version foo", ""},
+		{"show 1", "show"},
+		{"show 1 --json", ""},
+		{"build --tag v1", "build --tag v1"},
+		{"run ls -la", "run"},
+		{"plugin list --all", "plugins --all"},
+		{"version -- foo", "version"},
+	}
+	for _, tt := range tests {
+		got = ""
+		err := r.Run(context.Background(), strings.Fields(tt.argv))
+		var noMatch *NoMatchError
+		if tt.want == "" {
+			if !errors.As(err, &noMatch) {
+				t.Errorf("Run(%q): got=%q err=%v, want no match", tt.argv, got, err)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("Run(%q): got=%q err=%v, want %q", tt.argv, got, err, tt.want)
+		}
+	}
+
+	r.DisallowExtra(false)
+	if err := r.Run(context.Background(), strings.Fields("version foo")); err != nil || got != "version foo" {
+		t.Fatalf("after DisallowExtra(false): got=%q err=%v", got, err)
+	}
+}
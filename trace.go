@@ -0,0 +1,81 @@
+package clir
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// TraceResolvers returns middleware that logs every typed-context
+// resolution of the invocation to w: one line per resolver run with its
+// duration and outcome, and one per lookup answered from the memo (see
+// WithContext). Layers are named by the type they resolve, e.g.
+// "resolve main.AppCtx: 1.2ms" or "resolve *component.Adapter: 3ms
+// (error: unknown component)".
+//
+// The tracer sees every resolution made inside it, which includes the
+// handler's. Add it outermost to also see lookups from middleware that
+// calls FromContext; Before hooks run outside all middleware and are
+// never traced.
+//
+// Example:
+//
+//	app := clir.WithContext(b.With(clir.TraceResolvers(os.Stderr)), resolveApp)
+func TraceResolvers(w io.Writer) Middleware {
+	return func(next Handler) Handler {
+		return func(req *Request) error {
+			t := &resolverTracer{w: w}
+			return next(req.WithContext(context.WithValue(req.Context(), resolverTracerKey{}, t)))
+		}
+	}
+}
+
+type resolverTracerKey struct{}
+
+type resolverTracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (t *resolverTracer) printf(format string, args ...any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, format, args...)
+}
+
+// tracerOf returns the tracer installed by TraceResolvers, or nil.
+func tracerOf(req *Request) *resolverTracer {
+	t, _ := req.Context().Value(resolverTracerKey{}).(*resolverTracer)
+	return t
+}
+
+// traced runs resolve, logging it to the request's tracer if there is one.
+func traced[T any](name string, resolve Resolver[T], req *Request) (T, error) {
+	t := tracerOf(req)
+	if t == nil {
+		return resolve(req)
+	}
+	start := time.Now()
+	v, err := resolve(req)
+	if err != nil {
+		t.printf("resolve %s: %s (error: %v)\n", name, time.Since(start), err)
+	} else {
+		t.printf("resolve %s: %s\n", name, time.Since(start))
+	}
+	return v, err
+}
+
+// traceMemoized logs a lookup answered from the memo.
+func traceMemoized(req *Request, name string) {
+	if t := tracerOf(req); t != nil {
+		t.printf("resolve %s: memoized\n", name)
+	}
+}
+
+// typeName returns the name of T as shown in trace output.
+func typeName[T any]() string {
+	return reflect.TypeFor[T]().String()
+}
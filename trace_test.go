@@ -0,0 +1,54 @@
+package clir
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestTraceResolvers(t *testing.T) {
+	type App struct{ Name string }
+	type Comp struct{ Name string }
+
+	var trace bytes.Buffer
+	r := New()
+	r.Routes(func(b *Builder) {
+		app := WithContext(b.With(TraceResolvers(&trace)), func(*Request) (App, error) {
+			return App{Name: "cli"}, nil
+		})
+		comp := WithChildContext(app, func(_ App, req *Request) (Comp, error) {
+			if req.Params["component"] == "bad" {
+				return Comp{}, errors.New("unknown component")
+			}
+			return Comp{Name: req.Params["component"]}, nil
+		})
+		// Resolving Comp resolves App; the handler's own lookup of App is
+		// then answered from the memo.
+		comp.Handle("comp <component> show", "Show component", func(req *Request, _ Comp) error {
+			_, err := app.resolve(req)
+			return err
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"comp", "api", "show"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	want := regexp.MustCompile(`^resolve clir\.App: \S+\n` +
+		`resolve clir\.Comp: \S+\n` +
+		`resolve clir\.App: memoized\n$`)
+	if !want.MatchString(trace.String()) {
+		t.Fatalf("unexpected trace:\n%s", trace.String())
+	}
+
+	trace.Reset()
+	if err := r.Run(context.Background(), []string{"comp", "bad", "show"}); err == nil {
+		t.Fatal("expected the resolver error")
+	}
+	want = regexp.MustCompile(`^resolve clir\.App: \S+\n` +
+		`resolve clir\.Comp: \S+ \(error: unknown component\)\n$`)
+	if !want.MatchString(trace.String()) {
+		t.Fatalf("unexpected trace:\n%s", trace.String())
+	}
+}
@@ -0,0 +1,167 @@
+package clir
+
+// node is a trie node keyed on route segments. Literal children are looked
+// up by word, glob children are tried in turn, and all parameter segments
+// at the same depth share one child, since a parameter's name doesn't
+// affect matching. Rest and list segments likewise share one child, which
+// is always a leaf.
+type node struct {
+	lits   map[string]*node
+	globs  []globChild
+	param  *node
+	rest   *node
+	routes []int // indexes into Router.routes ending here, in registration order
+}
+
+type globChild struct {
+	glob string
+	node *node
+}
+
+// buildTrie indexes routes by their segments. Routes that can never match
+// (no segments, more than 32 segments, an empty segment, or a rest segment
+// that isn't last) are left out, mirroring matchArgv.
+func buildTrie(routes []route) *node {
+	root := &node{}
+	for i := range routes {
+		segs := routes[i].segments
+		if len(segs) == 0 || len(segs) > 32 {
+			continue
+		}
+
+		n := root
+		for j, s := range segs {
+			if s.last() && j != len(segs)-1 {
+				n = nil
+				break
+			}
+			n = n.child(s)
+			if n == nil {
+				break
+			}
+		}
+		if n != nil {
+			n.routes = append(n.routes, i)
+		}
+	}
+	return root
+}
+
+// child returns the child for segment s, creating it if needed.
+// It returns nil for segments that are neither literal, glob nor param.
+func (n *node) child(s segment) *node {
+	switch {
+	case s.lit != "":
+		if n.lits == nil {
+			n.lits = map[string]*node{}
+		}
+		c := n.lits[s.lit]
+		if c == nil {
+			c = &node{}
+			n.lits[s.lit] = c
+		}
+		return c
+	case s.glob != "":
+		for _, g := range n.globs {
+			if g.glob == s.glob {
+				return g.node
+			}
+		}
+		c := &node{}
+		n.globs = append(n.globs, globChild{s.glob, c})
+		return c
+	case s.last():
+		if n.rest == nil {
+			n.rest = &node{}
+		}
+		return n.rest
+	case s.param != "":
+		if n.param == nil {
+			n.param = &node{}
+		}
+		return n.param
+	default:
+		return nil
+	}
+}
+
+// match returns the index of the best route matching argv[i:], or -1.
+//
+// The search visits the literal child, then glob children, then the param
+// child, and all of them before routes ending at n. That is exactly the
+// order of matchArgv's rank (literal > glob > param > end of pattern,
+// earliest position dominating), so the first route found is the
+// highest-ranked one. Several globs can match the same token; their best
+// routes are compared by rank. Routes of equal rank resolve to the first
+// registered.
+//
+// A rest or list segment ranks like a param at its position and ranks
+// nothing after it, so it only ties with a param route ending at the same
+// depth.
+func (n *node) match(routes []route, argv []string, i int) int {
+	if i < len(argv) {
+		if c := n.lits[argv[i]]; c != nil {
+			if idx := c.match(routes, argv, i+1); idx != -1 {
+				return idx
+			}
+		}
+		if best := n.matchGlobs(routes, argv, i); best != -1 {
+			return best
+		}
+		if idx := n.matchParams(routes, argv, i); idx != -1 {
+			return idx
+		}
+	}
+	return n.first(routes, argv)
+}
+
+// first returns the first route ending at n that accepts argv, or -1.
+// Routes ending at the same node have the same rank.
+func (n *node) first(routes []route, argv []string) int {
+	for _, idx := range n.routes {
+		if routes[idx].accepts(argv) {
+			return idx
+		}
+	}
+	return -1
+}
+
+// matchGlobs returns the best route below the glob children matching
+// argv[i], or -1.
+func (n *node) matchGlobs(routes []route, argv []string, i int) int {
+	best := -1
+	var bestRank uint64
+	for _, g := range n.globs {
+		if !globMatch(g.glob, argv[i]) {
+			continue
+		}
+		idx := g.node.match(routes, argv, i+1)
+		if idx == -1 {
+			continue
+		}
+		if best == -1 && len(n.globs) == 1 {
+			return idx
+		}
+		rank := routes[idx].matchArgv(argv)
+		if best == -1 || rank > bestRank || (rank == bestRank && idx < best) {
+			best, bestRank = idx, rank
+		}
+	}
+	return best
+}
+
+// matchParams returns the best route below the param or rest child for
+// argv[i], or -1.
+func (n *node) matchParams(routes []route, argv []string, i int) int {
+	idx := -1
+	if n.param != nil {
+		idx = n.param.match(routes, argv, i+1)
+	}
+	if n.rest != nil {
+		r := n.rest.first(routes, argv)
+		if r != -1 && (idx == -1 || (len(routes[idx].segments) == i+1 && r < idx)) {
+			return r
+		}
+	}
+	return idx
+}
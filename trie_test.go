@@ -0,0 +1,260 @@
+package clir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTrie_AgreesWithLinearMatch(t *testing.T) {
+	patterns := []string{
+		"users <id>",
+		"users me",
+		"users <id> delete",
+		"users me delete",
+		"comp <component>",
+		"comp <component> image build",
+		"comp <component> image <image>",
+		"docker image <name> build",
+		"docker image list",
+		"a b <c>",
+		"a <b> <c> <d>",
+		"<any>",
+		"<any> <more>",
+		"cmd",
+		"cmd",
+		"2 sorted <x>",
+		"log *",
+		"log v?",
+		"log <n> tail",
+		"log v* tail",
+		"log *1 tail",
+		"stash@{*} drop",
+		"exec <cmd...>",
+		"exec <cmd>",
+		"exec ls <dir>",
+		"run <cmd>",
+		"run <cmd...>",
+		"ssh <host> <cmd...>",
+		"oops <a...> <b>",
+		"set <key>=<value>",
+		"set env=*",
+		"set <name>",
+		"set <k>=<v> now",
+		"tag add <tag>",
+		"tag add <tags+>",
+		"tag add x <more+>",
+		"tag <tags+> <oops>",
+	}
+	argvs := []string{
+		"",
+		"users",
+		"users me",
+		"users 42",
+		"users me delete",
+		"users 42 delete --force",
+		"comp api",
+		"comp api image build --tag latest",
+		"comp api image web",
+		"docker image list -v",
+		"docker image alpine build",
+		"a b x y",
+		"a z x y",
+		"cmd",
+		"sorted 1",
+		"bad x",
+		"unknown",
+		"unknown thing else",
+		"log v1",
+		"log v12",
+		"log v1 tail",
+		"log x1 tail",
+		"log x2 tail",
+		"stash@{0} drop",
+		"exec",
+		"exec ls",
+		"exec ls -la",
+		"exec ls -la --color",
+		"run make",
+		"run make all",
+		"ssh box",
+		"ssh box uptime --pretty",
+		"oops a b",
+		"set env=prod",
+		"set region=eu",
+		"set region=eu now",
+		"set =x",
+		"set k=",
+		"set plain",
+		"tag add a",
+		"tag add a b --push c",
+		"tag add --push",
+		"tag add x y z",
+		"tag add x --y",
+		"tag add a -- b",
+		"tag a b",
+	}
+
+	r := New()
+	for _, p := range patterns {
+		r.Handle(p, "desc", func(*Request) error { return nil })
+	}
+
+	for _, line := range argvs {
+		argv := strings.Fields(line)
+		t.Run(line, func(t *testing.T) {
+			wantRt, wantReq, wantOK := r.bestMatchLinear(context.Background(), argv)
+			gotRt, gotReq, gotOK := r.bestMatch(context.Background(), argv)
+
+			if gotOK != wantOK {
+				t.Fatalf("ok = %v, want %v", gotOK, wantOK)
+			}
+			if !wantOK {
+				return
+			}
+			if gotRt != wantRt {
+				t.Fatalf("route mismatch: got %q, want %q", gotRt.String(), wantRt.String())
+			}
+			if fmt.Sprint(gotReq.Params) != fmt.Sprint(wantReq.Params) {
+				t.Fatalf("params mismatch: got %v, want %v", gotReq.Params, wantReq.Params)
+			}
+			if fmt.Sprint(gotReq.Extra) != fmt.Sprint(wantReq.Extra) {
+				t.Fatalf("extra mismatch: got %v, want %v", gotReq.Extra, wantReq.Extra)
+			}
+			if fmt.Sprint(gotReq.ParamLists) != fmt.Sprint(wantReq.ParamLists) {
+				t.Fatalf("param lists mismatch: got %v, want %v", gotReq.ParamLists, wantReq.ParamLists)
+			}
+		})
+	}
+}
+
+func TestRouter_Build_RebuiltAfterHandle(t *testing.T) {
+	r := New()
+	r.Handle("users <id>", "Show user", func(*Request) error { return nil })
+	r.Build()
+
+	r.Handle("users me", "Show me", func(*Request) error { return nil })
+
+	rt, _, ok := r.bestMatch(context.Background(), []string{"users", "me"})
+	if !ok || rt.String() != "users me" {
+		t.Fatalf("expected newly registered route to match, got ok=%v route=%v", ok, rt)
+	}
+}
+
+func TestRoute_MatchArgv_NoAllocs(t *testing.T) {
+	rt := route{segments: parseSegments(strings.Fields("comp <component> image <image> build"))}
+	hit := []string{"comp", "api", "image", "web", "build"}
+	miss := []string{"docker", "api", "image", "web", "build"}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = rt.matchArgv(hit)
+		_ = rt.matchArgv(miss)
+	})
+	if allocs != 0 {
+		t.Fatalf("matchArgv allocated %v times per run, want 0", allocs)
+	}
+
+	params := rt.params(hit)
+	if params["component"] != "api" || params["image"] != "web" || len(params) != 2 {
+		t.Fatalf("unexpected params: %#v", params)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"v?", "v1", true},
+		{"v?", "v12", false},
+		{"stash@{*}", "stash@{0}", true},
+		{"stash@{*}", "stash@{0", false},
+		{"*.yaml", "a.b.yaml", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_GlobRanksBetweenLiteralAndParam(t *testing.T) {
+	r := New()
+
+	var got string
+	for _, p := range []string{"log <ref>", "log v*", "log v1"} {
+		pattern := p
+		r.Handle(pattern, "desc", func(req *Request) error {
+			got = pattern
+			if len(req.Params) > 0 && pattern != "log <ref>" {
+				t.Fatalf("glob should not capture params: %v", req.Params)
+			}
+			return nil
+		})
+	}
+
+	for argv, want := range map[string]string{
+		"log v1":   "log v1",
+		"log v2":   "log v*",
+		"log head": "log <ref>",
+	} {
+		if err := r.Run(context.Background(), strings.Fields(argv)); err != nil {
+			t.Fatalf("Run(%q) returned error: %v", argv, err)
+		}
+		if got != want {
+			t.Errorf("Run(%q) matched %q, want %q", argv, got, want)
+		}
+	}
+}
+
+func TestRouter_EscapedGlobIsLiteral(t *testing.T) {
+	r := New()
+	r.Handle(`list \*`, "List everything", func(*Request) error { return nil })
+
+	if err := r.Run(context.Background(), []string{"list", "*"}); err != nil {
+		t.Fatalf("Run(list *) returned error: %v", err)
+	}
+	var noMatch *NoMatchError
+	if err := r.Run(context.Background(), []string{"list", "all"}); !errors.As(err, &noMatch) {
+		t.Fatalf("escaped glob matched another token: %v", err)
+	}
+}
+
+func TestRouter_KeyValueSegment(t *testing.T) {
+	r := New()
+	var got *Request
+	h := func(req *Request) error {
+		got = req
+		return nil
+	}
+	r.Handle("set <key>=<value>", "Set a config value", h)
+	r.Handle("set env=prod", "Set the production env", h)
+	r.Handle("set <name>", "Show a config value", h)
+
+	tests := []struct {
+		argv    string
+		pattern string
+		params  string
+	}{
+		{"set region=eu-west=1", "set <key>=<value>", "map[key:region value:eu-west=1]"},
+		{"set region=", "set <key>=<value>", "map[key:region value:]"},
+		{"set env=prod", "set env=prod", "map[]"},
+		{"set region", "set <name>", "map[name:region]"},
+		{"set =eu", "set <name>", "map[name:=eu]"},
+	}
+	for _, tt := range tests {
+		got = nil
+		if err := r.Run(context.Background(), strings.Fields(tt.argv)); err != nil {
+			t.Fatalf("Run(%q) returned error: %v", tt.argv, err)
+		}
+		if got.Pattern() != tt.pattern || fmt.Sprint(got.Params) != tt.params {
+			t.Errorf("Run(%q) matched %q with %v, want %q with %s", tt.argv, got.Pattern(), got.Params, tt.pattern, tt.params)
+		}
+	}
+}
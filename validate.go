@@ -0,0 +1,113 @@
+package clir
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Validate checks the registered routes for likely mistakes and returns
+// all problems found, joined into one error (nil if none). It never
+// changes matching; call it from a test or at startup.
+//
+// Reported problems:
+//
+//   - routes whose first segment is a param, e.g. "<file> validate".
+//     Such a route matches any first token, so it can shadow mistyped
+//     commands. It never beats a route with a literal first segment,
+//     though: ranking compares segments left to right and a literal
+//     always outranks a param, regardless of length.
+//   - integer tokens that are consumed as sort hints without applying to
+//     any segment, e.g. "version 2" or "1 2 comp". They are most likely
+//     meant as literals; escape them as `\2`.
+//   - rest and list segments that aren't last, e.g. "cp <files+> <dest>".
+//     They consume the remaining tokens, so the route never matches.
+//   - aliases (see CommandOptions.Aliases) of the same shape as an
+//     earlier route, e.g. "co <branch>" after "co <file>". Ties go to the
+//     earliest registration, so the alias never matches.
+func (r *Router) Validate() error {
+	unlock := r.readLock()
+	defer unlock()
+
+	var errs []error
+	shapes := map[string]*route{}
+	for i := range r.routes {
+		rt := &r.routes[i]
+		shape := rt.shape()
+		if prev, ok := shapes[shape]; ok && rt.aliasOf != "" {
+			errs = append(errs, fmt.Errorf("route %q: alias of %q is shadowed by %q", rt.String(), rt.aliasOf, prev.String()))
+		} else if !ok {
+			shapes[shape] = rt
+		}
+		for _, err := range checkSortHints(rt.parts) {
+			errs = append(errs, fmt.Errorf("route %q: %w", strings.Join(rt.parts, " "), err))
+		}
+		if len(rt.segments) > 0 && rt.segments[0].param != "" {
+			errs = append(errs, fmt.Errorf("route %q: first segment is a param and matches any command (greedy)", rt.String()))
+		}
+		for j, s := range rt.segments {
+			if s.last() && j != len(rt.segments)-1 {
+				kind, suffix := "rest", "..."
+				if s.list {
+					kind, suffix = "list", "+"
+				}
+				errs = append(errs, fmt.Errorf("route %q: %s segment <%s%s> must be last", rt.String(), kind, s.param, suffix))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shape returns a key that is equal for routes matching the same argv
+// with the same rank, whatever their param names.
+func (rt *route) shape() string {
+	var b strings.Builder
+	for _, s := range rt.segments {
+		switch {
+		case s.lit != "":
+			b.WriteString("l" + strconv.Quote(s.lit))
+		case s.key != "":
+			b.WriteString("k")
+		case s.glob != "":
+			b.WriteString("g" + strconv.Quote(s.glob))
+		case s.rest:
+			b.WriteString("r")
+		case s.list:
+			b.WriteString("+")
+		default:
+			b.WriteString("p")
+			if s.req {
+				b.WriteString("!")
+			}
+			if s.noFlags {
+				b.WriteString("-")
+			}
+		}
+	}
+	return b.String()
+}
+
+// checkSortHints reports integer tokens in parts that parseSegments would
+// silently drop: a hint directly followed by another hint, or a hint at
+// the end of the pattern.
+func checkSortHints(parts []string) []error {
+	var errs []error
+	for i, p := range parts {
+		if !isSortHint(p) {
+			continue
+		}
+		switch {
+		case i == len(parts)-1:
+			errs = append(errs, fmt.Errorf("sort hint %q at the end applies to no segment (escape as `\\%s` to match it literally)", p, p))
+		case isSortHint(parts[i+1]):
+			errs = append(errs, fmt.Errorf("sort hint %q is overridden by %q (escape as `\\%s` to match it literally)", p, parts[i+1], p))
+		}
+	}
+	return errs
+}
+
+func isSortHint(p string) bool {
+	_, err := strconv.Atoi(p)
+	return err == nil
+}
@@ -0,0 +1,246 @@
+package clir
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRouter_Validate_ParamFirstRoute(t *testing.T) {
+	r := New()
+	r.Handle("comp <component> build", "Build", func(*Request) error { return nil })
+	if err := r.Validate(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	r.Handle("<file> validate", "Validate a file", func(*Request) error { return nil })
+	err := r.Validate()
+	if err == nil || !strings.Contains(err.Error(), `route "<file> validate": first segment is a param`) {
+		t.Fatalf("expected greedy route to be reported, got %v", err)
+	}
+}
+
+func TestRouter_ParamFirstRoute_NeverBeatsLiteralFirst(t *testing.T) {
+	r := New()
+
+	var got string
+	handle := func(pattern string) {
+		r.Handle(pattern, "desc", func(*Request) error {
+			got = pattern
+			return nil
+		})
+	}
+	// Register the param-first routes first so registration order can't
+	// explain the outcome.
+	handle("<file> validate")
+	handle("<a> <b> <c> <d>")
+	handle("comp")
+	handle("comp <component>")
+	handle("config validate")
+
+	tests := []struct {
+		argv string
+		want string
+	}{
+		{"comp validate", "comp <component>"},         // equal length
+		{"comp api x y", "comp <component>"},          // param-first route is longer
+		{"config validate", "config validate"},        // both fully literal vs param-first
+		{"schema.yaml validate", "<file> validate"},   // no literal-first alternative
+		{"unknown thing here now", "<a> <b> <c> <d>"}, // greedy fallback
+	}
+
+	for _, tt := range tests {
+		got = ""
+		if err := r.Run(context.Background(), strings.Fields(tt.argv)); err != nil {
+			t.Fatalf("Run(%q) returned error: %v", tt.argv, err)
+		}
+		if got != tt.want {
+			t.Errorf("Run(%q) matched %q, want %q", tt.argv, got, tt.want)
+		}
+	}
+}
+
+func TestParseSegments_SortHintsAndEscapes(t *testing.T) {
+	segs := parseSegments(strings.Fields(`1 comp <component> 2 \3 \<id> \\x`))
+
+	want := []segment{
+		{lit: "comp", sort: 1},
+		{param: "component"},
+		{lit: "3", sort: 2},
+		{lit: "<id>"},
+		{lit: `\x`},
+	}
+	if len(segs) != len(want) {
+		t.Fatalf("got %d segments, want %d: %+v", len(segs), len(want), segs)
+	}
+	for i := range want {
+		if segs[i] != want[i] {
+			t.Errorf("segment %d = %+v, want %+v", i, segs[i], want[i])
+		}
+	}
+}
+
+func TestRouter_SortHints_IgnoredByMatching(t *testing.T) {
+	r := New()
+
+	var got string
+	r.Handle("1 comp 2 build", "Build", func(*Request) error { got = "hint"; return nil })
+	r.Handle(`api \2`, "Version 2", func(*Request) error { got = "escaped"; return nil })
+
+	if err := r.Run(context.Background(), []string{"comp", "build"}); err != nil || got != "hint" {
+		t.Fatalf("sort hints should not be matched: got=%q err=%v", got, err)
+	}
+	if err := r.Run(context.Background(), []string{"api", "2"}); err != nil || got != "escaped" {
+		t.Fatalf("escaped integer should match literally: got=%q err=%v", got, err)
+	}
+}
+
+func TestRouter_QuotedIntegerLiteral(t *testing.T) {
+	r := New()
+
+	var got string
+	r.Handle(`ipv "4" route <cidr>`, "IPv4 route", func(req *Request) error { got = "4 " + req.Params["cidr"]; return nil })
+	r.Handle(`ipv '6' route <cidr>`, "IPv6 route", func(req *Request) error { got = "6 " + req.Params["cidr"]; return nil })
+	r.Handle(`ipv <version> route <cidr>`, "Other route", func(req *Request) error { got = "param"; return nil })
+
+	for argv, want := range map[string]string{
+		"ipv 4 route 10.0.0.0/8": "4 10.0.0.0/8",
+		"ipv 6 route ::/0":       "6 ::/0",
+		"ipv 5 route x":          "param",
+	} {
+		got = ""
+		if err := r.Run(context.Background(), strings.Fields(argv)); err != nil || got != want {
+			t.Errorf("Run(%q): got=%q err=%v, want %q", argv, got, err, want)
+		}
+	}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("quoted integers should not be reported as sort hints: %v", err)
+	}
+	// Rendered escaped, so it parses back to the same literal.
+	if p := r.routes[0].String(); p != `ipv \4 route <cidr>` {
+		t.Fatalf("unexpected pattern string: %s", p)
+	}
+}
+
+func TestRouter_Validate_DanglingSortHints(t *testing.T) {
+	r := New()
+	noop := func(*Request) error { return nil }
+	r.Handle("1 comp 2 build", "Fine", noop)
+	r.Handle(`api \2`, "Fine", noop)
+	r.Handle("api 2", "Trailing", noop)
+	r.Handle("1 2 comp", "Overridden", noop)
+
+	err := r.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`route "api 2": sort hint "2" at the end applies to no segment`,
+		`route "1 2 comp": sort hint "1" is overridden by "2"`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing %q in:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "Fine") || strings.Count(msg, "\n") != 1 {
+		t.Errorf("unexpected errors:\n%s", msg)
+	}
+}
+
+func TestSplitPattern_Quotes(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"comp <component>  build", []string{"comp", "<component>", "build"}},
+		{`admin "add user" <name>`, []string{"admin", `\add user`, "<name>"}},
+		{`say 'hello world'`, []string{"say", `\hello world`}},
+		{`"1" "<id>"`, []string{`\1`, `\<id>`}},
+		{`it's fine`, []string{"it's", "fine"}},
+		{`open "unterminated quote`, []string{"open", `\unterminated quote`}},
+	}
+
+	for _, tt := range tests {
+		got := splitPattern(tt.pattern)
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("splitPattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestRouter_QuotedLiteral(t *testing.T) {
+	r := New()
+
+	var got Params
+	r.Routes(func(b *Builder) {
+		b.Route(`admin "add user"`, func(b *Builder) {
+			b.Handle("<name>", "Add a user", func(req *Request) error {
+				got = req.Params
+				return nil
+			})
+		})
+	})
+
+	if err := r.Run(context.Background(), []string{"admin", "add user", "alice"}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got["name"] != "alice" {
+		t.Fatalf("unexpected params: %v", got)
+	}
+	if err := r.Run(context.Background(), []string{"admin", "add", "user", "alice"}); err == nil {
+		t.Fatal("separate tokens should not match a quoted literal")
+	}
+	if p := r.routes[0].String(); p != `admin "add user" <name>` {
+		t.Fatalf("unexpected pattern string: %s", p)
+	}
+}
+
+func TestRouter_Validate_RestNotLast(t *testing.T) {
+	r := New()
+	r.Handle("cp <files...> <dest>", "Copy files", func(*Request) error { return nil })
+	r.Handle("exec <cmd...>", "Run a command", func(*Request) error { return nil })
+
+	err := r.Validate()
+	want := `route "cp <files...> <dest>": rest segment <files...> must be last`
+	if err == nil || err.Error() != want {
+		t.Fatalf("Validate() = %v, want %q", err, want)
+	}
+	if _, ok := r.lookup([]string{"cp", "a", "b"}); ok {
+		t.Fatal("a route with a non-final rest segment should never match")
+	}
+}
+
+func TestRouter_Handle_PanicsOnMalformedParams(t *testing.T) {
+	noop := func(*Request) error { return nil }
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"comp <component", `unbalanced angle brackets in "<component"`},
+		{"comp component>", `unbalanced angle brackets in "component>"`},
+		{"bad <>", `empty param name in "<>"`},
+		{"exec <...>", `empty param name in "<...>"`},
+		{"x <a<b>", `malformed param "<a<b>"`},
+		{"set <k>=<<v>", `malformed param "<k>=<<v>"`},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.Contains(msg, tt.want) {
+					t.Errorf("Handle(%q) panic = %q, want %q", tt.pattern, msg, tt.want)
+				}
+			}()
+			New().Handle(tt.pattern, "Broken", noop)
+		}()
+	}
+
+	// Escaped and quoted brackets are literals.
+	r := New()
+	r.Handle(`cmp \<id "<x"`, "Literal brackets", noop)
+	if _, ok := r.lookup([]string{"cmp", "<id", "<x"}); !ok {
+		t.Fatal("escaped brackets should match literally")
+	}
+}